@@ -0,0 +1,237 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/options"
+)
+
+// The kinds of edges between a module and the things it depends on
+const (
+	// A parent config pulled in with an `include` block
+	dependencyKindInclude = "include"
+	// A module referenced by a `dependency` or `dependencies` block
+	dependencyKindDependency = "dependency"
+	// The terraform source of a module, and the local modules it calls
+	dependencyKindSource = "source"
+	// A path listed in the `extra_atlantis_dependencies` local
+	dependencyKindExtra = "extra"
+	// A var file passed in the `extra_arguments` of the `terraform` block
+	dependencyKindVarFile = "var-file"
+)
+
+var allDependencyKinds = []string{
+	dependencyKindInclude,
+	dependencyKindDependency,
+	dependencyKindSource,
+	dependencyKindExtra,
+	dependencyKindVarFile,
+}
+
+// Only these kinds of edges point at terragrunt configs that can have dependencies of their own
+var traversableDependencyKinds = []string{
+	dependencyKindInclude,
+	dependencyKindDependency,
+	dependencyKindExtra,
+}
+
+// A single thing a module depends on
+type dependency struct {
+	// The absolute path of the dependency, which may be a glob
+	path string
+
+	// How the module came to depend on the path
+	kind string
+}
+
+// CascadeLocals are the settings of the `atlantis_cascade` local, unset values fall back to the flags
+type CascadeLocals struct {
+	// If set, turns cascading on or off for just this module
+	Enabled *bool
+
+	// If set, the edge kinds to cascade through for just this module
+	Edges []string
+
+	// If set, the max cascading depth for just this module
+	Depth *int
+}
+
+// The cascade settings used when walking the dependencies of a single module
+type cascadeSettings struct {
+	enabled bool
+	edges   []string
+	depth   int
+}
+
+// Merges in values from a child into a parent set of `atlantis_cascade` values
+func mergeCascadeLocals(parent CascadeLocals, child CascadeLocals) CascadeLocals {
+	if child.Enabled != nil {
+		parent.Enabled = child.Enabled
+	}
+
+	if child.Edges != nil {
+		parent.Edges = child.Edges
+	}
+
+	if child.Depth != nil {
+		parent.Depth = child.Depth
+	}
+
+	return parent
+}
+
+// Combines the cascade flags with the `atlantis_cascade` local of a module
+func resolveCascadeSettings(locals CascadeLocals) cascadeSettings {
+	settings := cascadeSettings{
+		enabled: cascadeDependencies,
+		edges:   cascadeEdges,
+		depth:   cascadeDepth,
+	}
+
+	if locals.Enabled != nil {
+		settings.enabled = *locals.Enabled
+	}
+
+	if locals.Edges != nil {
+		settings.edges = locals.Edges
+	}
+
+	if locals.Depth != nil {
+		settings.depth = *locals.Depth
+	}
+
+	return settings
+}
+
+// Ensures every kind in a list of cascade edges is known
+func validateCascadeEdges(edges []string) error {
+	for _, edge := range edges {
+		if !containsString(allDependencyKinds, edge) {
+			return fmt.Errorf("unknown cascade edge kind %q, must be one of: %s", edge, strings.Join(allDependencyKinds, ", "))
+		}
+	}
+	return nil
+}
+
+// Ensures a max cascading depth is 0, meaning unlimited, or a positive number of levels
+func validateCascadeDepth(depth int) error {
+	if depth < 0 {
+		return fmt.Errorf("invalid cascade depth %d, must be 0 for unlimited or a positive number of levels", depth)
+	}
+	return nil
+}
+
+// Walks the dependencies of dependencies of a single module, following the cascade settings of that module
+type cascadeWalker struct {
+	ctx      *config.ParsingContext
+	settings cascadeSettings
+
+	// The configs on the current path of the walk, used to break cycles
+	visiting map[string]bool
+
+	// The dependencies already walked, keyed by `resultKey`, so configs shared by many paths through the
+	// dependency graph are only walked once
+	results map[string][]string
+}
+
+func newCascadeWalker(ctx *config.ParsingContext, path string, settings cascadeSettings) *cascadeWalker {
+	return &cascadeWalker{
+		ctx:      ctx,
+		settings: settings,
+		visiting: map[string]bool{path: true},
+		results:  map[string][]string{},
+	}
+}
+
+// Returns the key of the result of walking `path` at `level`, which only depends on how many more levels
+// may be walked from there
+func (w *cascadeWalker) resultKey(path string, level int) string {
+	if w.settings.depth == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s|%d", path, w.settings.depth-level)
+}
+
+// Returns the dependencies of `dep` that should cascade to the module being walked, where `level` is
+// the number of hops `dep` is away from that module. Only edges of the configured kinds are cascaded,
+// so with just `source` enabled a module picks up the module sources of its dependencies, but not
+// the dependencies of its dependencies.
+//
+// Also returns whether the result is complete. Results cut short by a cycle depend on the path the walk took,
+// so only complete results are reused.
+func (w *cascadeWalker) walk(dep dependency, level int) ([]string, bool) {
+	if !w.settings.enabled || !containsString(traversableDependencyKinds, dep.kind) {
+		return nil, true
+	}
+	if w.settings.depth > 0 && level > w.settings.depth {
+		return nil, true
+	}
+	if w.visiting[dep.path] {
+		return nil, false
+	}
+	key := w.resultKey(dep.path, level)
+	if cascaded, ok := w.results[key]; ok {
+		return cascaded, true
+	}
+	w.visiting[dep.path] = true
+	defer delete(w.visiting, dep.path)
+
+	terrOpts, _ := options.NewTerragruntOptionsWithConfigPath(dep.path)
+	terrOpts.OriginalTerragruntConfigPath = w.ctx.TerragruntOptions.OriginalTerragruntConfigPath
+	terrOpts.Env = w.ctx.TerragruntOptions.Env
+	terrOpts.FeatureFlags = w.ctx.TerragruntOptions.FeatureFlags
+	terrContext := newParsingContext(w.ctx, terrOpts)
+
+	// Anything that fails to parse, like a glob or a plain file, simply has nothing to cascade, but a
+	// config that fails to parse is reported
+	childOutput, err := getDirectDependencies(terrContext, dep.path)
+	if err != nil {
+		if isConfigFile(dep.path) {
+			reportCascadeFailure(w.ctx, dep.path, w.ctx.TerragruntOptions.OriginalTerragruntConfigPath, err)
+		}
+		w.results[key] = nil
+		return nil, true
+	}
+
+	cascaded := []string{}
+	complete := true
+	for _, childDep := range childOutput.dependencies {
+		if !containsString(w.settings.edges, childDep.kind) {
+			continue
+		}
+
+		childCascaded, childComplete := w.walk(childDep, level+1)
+		complete = complete && childComplete
+		for _, path := range append([]string{childDep.path}, childCascaded...) {
+			if !containsString(cascaded, path) {
+				cascaded = append(cascaded, path)
+			}
+		}
+	}
+
+	if complete {
+		w.results[key] = cascaded
+	}
+	return cascaded, complete
+}
+
+// Checks if a path is already listed in a set of dependencies
+func containsDependency(dependencies []dependency, path string) bool {
+	for _, dep := range dependencies {
+		if dep.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/util"
+)
+
+// The names of terragrunt configs when `--config-filename` isn't given
+var defaultConfigFilenames = []string{"terragrunt.hcl"}
+
+// Returns the `--config-filename` values, or the default names if none were given
+func configuredFilenames() []string {
+	if len(configFilenames) > 0 {
+		return configFilenames
+	}
+	return defaultConfigFilenames
+}
+
+// Returns the names a terragrunt config can have, in order of precedence. Each name can also be written in the
+// other syntax, so `terragrunt.hcl` also stands for `terragrunt.hcl.json`. Like terragrunt, the JSON variant
+// takes precedence when a directory has both.
+func configFileNames() []string {
+	names := []string{}
+	for _, filename := range configuredFilenames() {
+		hclName := strings.TrimSuffix(filename, ".json")
+		if strings.HasSuffix(hclName, ".hcl") {
+			names = append(names, hclName+".json", hclName)
+		} else {
+			names = append(names, filename)
+		}
+	}
+	return uniqueStrings(names)
+}
+
+// Checks if `name` is the file name of a terragrunt config
+func isConfigFilename(name string) bool {
+	return containsString(configFileNames(), name)
+}
+
+// Returns the path of the terragrunt config in `dir`, with relative directories resolved against the directory of
+// `parentPath`. If the directory has no config, the path of a config with the first configured name is returned,
+// so that the dependency still shows up in `when_modified`.
+func configFileInDir(dir string, parentPath string) string {
+	absoluteDir := dir
+	if !filepath.IsAbs(absoluteDir) {
+		absoluteDir = makePathAbsolute(dir, parentPath)
+	}
+
+	for _, name := range configFileNames() {
+		if configFile := filepath.Join(absoluteDir, name); !util.IsDir(configFile) && util.FileExists(configFile) {
+			return filepath.Join(dir, name)
+		}
+	}
+	return filepath.Join(dir, configuredFilenames()[0])
+}
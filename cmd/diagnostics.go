@@ -0,0 +1,410 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// The formats diagnostics can be written in
+const (
+	diagnosticsFormatJSON  = "json"
+	diagnosticsFormatSARIF = "sarif"
+)
+
+// The severities of diagnostics, named like SARIF levels
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityNote    = "note"
+)
+
+// The IDs of the rules diagnostics are reported under
+const (
+	ruleGenerationFailed         = "generation-failed"
+	ruleProjectFailed            = "project-failed"
+	ruleIncludeCycle             = "include-cycle"
+	ruleNonStringExtraDependency = "non-string-extra-dependency"
+	ruleUnparseableConfig        = "unparseable-config"
+	ruleModuleSkipped            = "module-skipped"
+	ruleParentConfigSkipped      = "parent-config-skipped"
+	ruleConflictingAtlantisLocal = "conflicting-atlantis-local"
+	ruleTerraformVersionConflict = "terraform-version-conflict"
+	ruleExecutionOrderCycle      = "execution-order-cycle"
+	ruleUnitTimedOut             = "unit-timed-out"
+	ruleUnitInterrupted          = "unit-interrupted"
+	ruleSandboxedCall            = "sandboxed-call"
+	ruleEnvVarsRead              = "env-vars-read"
+)
+
+// A rule diagnostics are reported under
+type diagnosticRule struct {
+	severity    string
+	description string
+
+	// The level diagnostics are logged at. Errors are returned, or logged along with the failure they cause, so
+	// they are only logged at debug level here.
+	logLevel log.Level
+}
+
+var diagnosticRules = map[string]diagnosticRule{
+	ruleGenerationFailed:         {severityError, "Generation failed before any project could be created", log.DebugLevel},
+	ruleProjectFailed:            {severityError, "The project of a config could not be created", log.DebugLevel},
+	ruleIncludeCycle:             {severityError, "A chain of include blocks includes the same config twice", log.DebugLevel},
+	ruleNonStringExtraDependency: {severityError, "extra_atlantis_dependencies contains a value that is not a string", log.DebugLevel},
+	ruleUnparseableConfig:        {severityWarning, "A config could not be parsed while cascading the dependencies of another module", log.WarnLevel},
+	ruleModuleSkipped:            {severityNote, "A module was skipped by its atlantis_skip local", log.DebugLevel},
+	ruleParentConfigSkipped:      {severityNote, "A parent config was skipped because of --ignore-parent-terragrunt", log.DebugLevel},
+	ruleConflictingAtlantisLocal: {severityWarning, "A setting is given both in the nested atlantis local and as a prefixed local", log.WarnLevel},
+	ruleTerraformVersionConflict: {severityWarning, "Detected terraform versions and required_version constraints disagree", log.WarnLevel},
+	ruleExecutionOrderCycle:      {severityWarning, "execution_order_group could not be computed, probably because of a dependency cycle", log.WarnLevel},
+	ruleUnitTimedOut:             {severityError, "A config was not evaluated within --unit-timeout", log.DebugLevel},
+	ruleUnitInterrupted:          {severityError, "A config was still being evaluated when generation timed out or was interrupted", log.DebugLevel},
+	ruleSandboxedCall:            {severityNote, "A side-effecting terragrunt function was replaced by a stub because of --sandbox", log.InfoLevel},
+	ruleEnvVarsRead:              {severityNote, "The env vars read with get_env while evaluating the configs of a project", log.InfoLevel},
+}
+
+// A finding about the generation of a project, which can be written in a machine-readable format
+type diagnostic struct {
+	ruleID  string
+	message string
+
+	// The absolute path of the file the finding is about, if any
+	file string
+
+	// Where in the file the finding is, if known
+	rng *hcl.Range
+}
+
+// Collects diagnostics from concurrent project generation
+type diagnosticsCollector struct {
+	mtx         sync.Mutex
+	diagnostics []diagnostic
+}
+
+func (c *diagnosticsCollector) add(d diagnostic) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.diagnostics = append(c.diagnostics, d)
+}
+
+// Returns the diagnostics sorted by file and position
+func (c *diagnosticsCollector) list() []diagnostic {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	diagnostics := append([]diagnostic{}, c.diagnostics...)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		if a.rng != nil && b.rng != nil && a.rng.Start.Byte != b.rng.Start.Byte {
+			return a.rng.Start.Byte < b.rng.Start.Byte
+		}
+		return a.ruleID < b.ruleID
+	})
+	return diagnostics
+}
+
+// Checks if any error was reported
+func (c *diagnosticsCollector) hasErrors() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, d := range c.diagnostics {
+		if diagnosticRules[d.ruleID].severity == severityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Reports a finding about `file` under a rule, in the run of `ctx`, and logs it at the level of the rule
+func reportDiagnostic(ctx context.Context, ruleID string, file string, rng *hcl.Range, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	runOf(ctx).diagnostics.add(diagnostic{ruleID: ruleID, message: message, file: file, rng: rng})
+
+	if file != "" {
+		relativePath, err := filepath.Rel(gitRoot, file)
+		if err != nil {
+			relativePath = file
+		}
+		message = filepath.ToSlash(relativePath) + ": " + message
+	}
+	log.StandardLogger().Log(diagnosticRules[ruleID].logLevel, message)
+}
+
+// Reports an error about `file`, under the rule matching the kind of the error, or `fallbackRuleID`
+func reportErrorDiagnostic(ctx context.Context, fallbackRuleID string, file string, err error) {
+	ruleID := fallbackRuleID
+	var cycleErr *includeCycleError
+	var extraDependencyErr *nonStringExtraDependencyError
+	var unitTimeoutErr *unitTimeoutError
+	switch {
+	case goerrors.As(err, &cycleErr):
+		ruleID = ruleIncludeCycle
+	case goerrors.As(err, &extraDependencyErr):
+		ruleID = ruleNonStringExtraDependency
+	case goerrors.As(err, &unitTimeoutErr):
+		ruleID = ruleUnitTimedOut
+	}
+
+	rng := diagnosticRange(err)
+	if rng != nil && rng.Filename != "" {
+		file = rng.Filename
+	}
+	reportDiagnostic(ctx, ruleID, file, rng, "%s", err.Error())
+}
+
+// Returned when a chain of `include` blocks includes the same config twice
+type includeCycleError struct {
+	chain []string
+}
+
+func (e *includeCycleError) Error() string {
+	return fmt.Sprintf("include cycle detected: %s", strings.Join(e.chain, " -> "))
+}
+
+// Returned when `extra_atlantis_dependencies` contains a value that is not a string
+type nonStringExtraDependencyError struct {
+	position int64
+}
+
+func (e *nonStringExtraDependencyError) Error() string {
+	return fmt.Sprintf("extra_atlantis_dependencies contains non-string value at position %d", e.position)
+}
+
+// Runs `generate`, then writes the diagnostics of the run, even when it failed
+func generateWithDiagnostics(cmd *cobra.Command, args []string) error {
+	if err := validateDiagnosticsFormat(diagnosticsFormat); err != nil {
+		return err
+	}
+	ctx := startRun(context.Background())
+
+	err := main(ctx, cmd, args)
+
+	// Errors of single projects are reported where they happen, anything else failed the whole run
+	var partialFailure *partialFailureError
+	if err != nil && !goerrors.As(err, &partialFailure) && !runOf(ctx).diagnostics.hasErrors() {
+		reportErrorDiagnostic(ctx, ruleGenerationFailed, "", err)
+	}
+
+	if diagnosticsErr := writeDiagnostics(ctx, cmd.OutOrStdout()); diagnosticsErr != nil && err == nil {
+		return diagnosticsErr
+	}
+	return err
+}
+
+// Ensures a diagnostics format is supported, an empty format meaning no diagnostics are written
+func validateDiagnosticsFormat(format string) error {
+	switch format {
+	case "", diagnosticsFormatJSON, diagnosticsFormatSARIF:
+		return nil
+	}
+	return fmt.Errorf("unsupported diagnostics format %q, must be one of %q or %q", format, diagnosticsFormatJSON, diagnosticsFormatSARIF)
+}
+
+// Writes the diagnostics of the run in the format of the `--diagnostics-format` flag, to the file of the
+// `--diagnostics-output` flag or to `out`
+func writeDiagnostics(ctx context.Context, out io.Writer) error {
+	if diagnosticsFormat == "" {
+		return nil
+	}
+
+	var document interface{}
+	if diagnosticsFormat == diagnosticsFormatSARIF {
+		document = sarifDocument(runOf(ctx).diagnostics.list())
+	} else {
+		document = jsonDocument(runOf(ctx).diagnostics.list())
+	}
+
+	bytes, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return err
+	}
+	bytes = append(bytes, '\n')
+
+	if diagnosticsOutput != "" {
+		return os.WriteFile(diagnosticsOutput, bytes, 0644)
+	}
+	_, err = out.Write(bytes)
+	return err
+}
+
+// Returns the path of a file relative to the root, with Unix path separators
+func diagnosticPath(file string) string {
+	if relative, err := filepath.Rel(gitRoot, file); err == nil {
+		return filepath.ToSlash(relative)
+	}
+	return filepath.ToSlash(file)
+}
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonRange struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonDiagnostic struct {
+	RuleID   string     `json:"rule_id"`
+	Severity string     `json:"severity"`
+	Message  string     `json:"message"`
+	File     string     `json:"file,omitempty"`
+	Range    *jsonRange `json:"range,omitempty"`
+}
+
+type jsonDiagnostics struct {
+	Diagnostics []jsonDiagnostic `json:"diagnostics"`
+}
+
+func jsonDocument(diagnostics []diagnostic) jsonDiagnostics {
+	document := jsonDiagnostics{Diagnostics: []jsonDiagnostic{}}
+	for _, d := range diagnostics {
+		entry := jsonDiagnostic{
+			RuleID:   d.ruleID,
+			Severity: diagnosticRules[d.ruleID].severity,
+			Message:  d.message,
+		}
+		if d.file != "" {
+			entry.File = diagnosticPath(d.file)
+		}
+		if d.rng != nil {
+			entry.Range = &jsonRange{
+				Start: jsonPosition{Line: d.rng.Start.Line, Column: d.rng.Start.Column},
+				End:   jsonPosition{Line: d.rng.End.Line, Column: d.rng.End.Column},
+			}
+		}
+		document.Diagnostics = append(document.Diagnostics, entry)
+	}
+	return document
+}
+
+// The subset of SARIF 2.1.0 needed to report diagnostics to code scanning tools
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn"`
+}
+
+func sarifDocument(diagnostics []diagnostic) sarifLog {
+	rules := []sarifRule{}
+	for _, id := range sortedKeys(diagnosticRules) {
+		rules = append(rules, sarifRule{
+			ID:                   id,
+			ShortDescription:     sarifMessage{Text: diagnosticRules[id].description},
+			DefaultConfiguration: sarifConfiguration{Level: diagnosticRules[id].severity},
+		})
+	}
+
+	results := []sarifResult{}
+	for _, d := range diagnostics {
+		result := sarifResult{
+			RuleID:  d.ruleID,
+			Level:   diagnosticRules[d.ruleID].severity,
+			Message: sarifMessage{Text: d.message},
+		}
+		if d.file != "" {
+			location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: diagnosticPath(d.file), URIBaseID: "%SRCROOT%"},
+			}}
+			if d.rng != nil {
+				location.PhysicalLocation.Region = &sarifRegion{
+					StartLine:   d.rng.Start.Line,
+					StartColumn: d.rng.Start.Column,
+					EndLine:     d.rng.End.Line,
+					EndColumn:   d.rng.End.Column,
+				}
+			}
+			result.Locations = []sarifLocation{location}
+		}
+		results = append(results, result)
+	}
+
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           rootCmd.Use,
+				Version:        VERSION,
+				InformationURI: "https://github.com/transcend-io/terragrunt-atlantis-config",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
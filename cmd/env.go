@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Builds the environment `get_env` sees: the environment of the process, or nothing with `--clean-env`,
+// overridden by each `--env-file` in order, and then by the `--env` values
+func loadEnv() (map[string]string, error) {
+	env := map[string]string{}
+	if !cleanEnv {
+		for _, pair := range os.Environ() {
+			results := strings.SplitN(pair, "=", 2)
+			env[results[0]] = results[1]
+		}
+	}
+
+	for _, path := range envFiles {
+		fileEnv, err := readEnvFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileEnv {
+			env[key] = value
+		}
+	}
+
+	for _, pair := range envValues {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --env %q, expected KEY=VALUE", pair)
+		}
+		env[key] = value
+	}
+	return env, nil
+}
+
+// Reads a file of `KEY=VALUE` lines, like a `.env` file. Blank lines and lines starting with `#` are skipped,
+// keys may be prefixed with `export`, and values may be wrapped in single or double quotes.
+func readEnvFile(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, lineNumber)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+	return env, scanner.Err()
+}
+
+// Records the env vars read by `get_env` while evaluating each config, keyed by the config's path, along with
+// whether each one was set
+type envReadTracker struct {
+	mtx   sync.Mutex
+	reads map[string]map[string]bool
+}
+
+func newEnvReadTracker() *envReadTracker {
+	return &envReadTracker{reads: map[string]map[string]bool{}}
+}
+
+func (t *envReadTracker) add(path string, name string, isSet bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.reads[path] == nil {
+		t.reads[path] = map[string]bool{}
+	}
+	t.reads[path][name] = isSet
+}
+
+// Returns the env vars read while evaluating any of `paths`, along with whether each one was set
+func (t *envReadTracker) get(paths []string) map[string]bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	reads := map[string]bool{}
+	for _, path := range paths {
+		for name, isSet := range t.reads[path] {
+			reads[name] = isSet
+		}
+	}
+	return reads
+}
+
+// Returns a `get_env` that behaves like the terragrunt one, but records the env vars it reads while
+// evaluating the config at `path`
+func envFunction(parsingContext *config.ParsingContext, path string) function.Function {
+	return function.New(&function.Spec{
+		VarParam: &function.Parameter{Type: cty.String},
+		Type:     function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			parameters := []string{}
+			for _, arg := range args {
+				if arg.IsNull() || !arg.IsKnown() {
+					return cty.UnknownVal(cty.String), nil
+				}
+				parameters = append(parameters, arg.AsString())
+			}
+
+			if len(parameters) > 0 {
+				_, isSet := parsingContext.TerragruntOptions.Env[parameters[0]]
+				runOf(parsingContext).envReads.add(path, parameters[0], isSet)
+			}
+			value, err := getEnvironmentVariable(parsingContext.TerragruntOptions.Env, parameters)
+			if err != nil {
+				return cty.StringVal(""), err
+			}
+			return cty.StringVal(value), nil
+		},
+	})
+}
+
+// Returns the value of the env var named by the first of `parameters` in `env`, like terragrunt's `get_env`. The
+// second parameter is the default for an unset env var, and without one the env var is required.
+func getEnvironmentVariable(env map[string]string, parameters []string) (string, error) {
+	if len(parameters) != 1 && len(parameters) != 2 {
+		return "", errors.WithStackTrace(config.InvalidGetEnvParamsError{ActualNumParams: len(parameters), Example: `getEnv("<NAME>", "[DEFAULT]")`})
+	}
+	name := parameters[0]
+	if name == "" {
+		return "", errors.WithStackTrace(config.InvalidEnvParamNameError{EnvName: name})
+	}
+
+	value, ok := env[name]
+	if ok {
+		return value, nil
+	}
+	if len(parameters) == 1 {
+		return "", errors.WithStackTrace(config.EnvVarNotFoundError{EnvVar: name})
+	}
+	return parameters[1], nil
+}
+
+// Reports the env vars that influenced the project of `configPath`, read while evaluating any of `paths`
+func reportEnvVarsRead(ctx context.Context, configPath string, paths []string) {
+	reads := runOf(ctx).envReads.get(paths)
+	if len(reads) == 0 {
+		return
+	}
+
+	names := []string{}
+	for _, name := range sortedKeys(reads) {
+		if !reads[name] {
+			name += " (unset)"
+		}
+		names = append(names, name)
+	}
+	reportDiagnostic(ctx, ruleEnvVarsRead, configPath, nil, "read env vars %s", strings.Join(names, ", "))
+}
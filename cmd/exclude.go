@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Directories that hold copies of configs and modules, which are never searched
+var builtinExcludedDirs = []string{".git", ".terragrunt-cache", ".terraform"}
+
+// The names of the files read with `--use-ignore-files`, in order of precedence
+var ignoreFilenames = []string{".gitignore", ".atlantisignore"}
+
+// A single pattern of an ignore file, following the `.gitignore` syntax
+type ignoreRule struct {
+	// The pattern, without its negation, anchoring or trailing slash
+	pattern string
+
+	// If set, the pattern re-includes paths excluded by earlier patterns
+	negated bool
+
+	// If set, the pattern matches against the path relative to the ignore file, instead of the base name
+	anchored bool
+
+	// If set, the pattern only matches directories
+	dirOnly bool
+}
+
+// Checks if `relativePath`, relative to the directory of the rule's ignore file, matches the rule
+func (r ignoreRule) matches(relativePath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.anchored {
+		// Like with git, `dir/**` matches everything inside `dir`, but not `dir` itself, so paths in it can
+		// still be re-included
+		if base := strings.TrimSuffix(r.pattern, "/**"); base != r.pattern {
+			if matched, _ := matchPathGlob(base, relativePath); matched {
+				return false
+			}
+		}
+		matched, _ := matchPathGlob(r.pattern, relativePath)
+		return matched
+	}
+	matched, _ := path.Match(r.pattern, path.Base(relativePath))
+	return matched
+}
+
+// Parses the lines of an ignore file. Lines that aren't valid patterns are skipped, like git does.
+func parseIgnoreRules(contents string) []ignoreRule {
+	rules := []ignoreRule{}
+	scanner := bufio.NewScanner(strings.NewReader(contents))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negated = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		if _, err := matchPathGlob(line, ""); err != nil {
+			continue
+		}
+
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Caches the rules of the ignore files in each directory, as they are read for every path checked
+type ignoreRulesCache struct {
+	mtx   sync.Mutex
+	rules map[string][]ignoreRule
+}
+
+func newIgnoreRulesCache() *ignoreRulesCache {
+	return &ignoreRulesCache{rules: map[string][]ignoreRule{}}
+}
+
+// Returns the rules of the ignore files in `dir`, or none if it has no ignore files
+func (c *ignoreRulesCache) get(dir string) []ignoreRule {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if rules, ok := c.rules[dir]; ok {
+		return rules
+	}
+
+	rules := []ignoreRule{}
+	for _, filename := range ignoreFilenames {
+		contents, err := os.ReadFile(filepath.Join(dir, filename))
+		if err != nil {
+			continue
+		}
+		rules = append(rules, parseIgnoreRules(string(contents))...)
+	}
+	c.rules[dir] = rules
+	return rules
+}
+
+// Checks that every `--exclude` pattern is a valid glob
+func validateExcludePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := matchPathGlob(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --exclude pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Checks if the absolute `absolutePath` is excluded from discovery and `when_modified` scanning. A path is
+// excluded when it, or any directory it is in, is a built-in excluded directory, matches an `--exclude`
+// pattern or, with `--use-ignore-files`, is ignored by an ignore file. Paths outside the root are never excluded.
+func isExcludedPath(ctx context.Context, absolutePath string, isDir bool) bool {
+	absolutePath, err := filepath.Abs(absolutePath)
+	if err != nil {
+		return false
+	}
+	relativePath, err := filepath.Rel(gitRoot, absolutePath)
+	if err != nil || relativePath == "." || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	segments := strings.Split(filepath.ToSlash(relativePath), "/")
+	for i, segment := range segments {
+		prefix := strings.Join(segments[:i+1], "/")
+		prefixIsDir := isDir || i < len(segments)-1
+
+		if prefixIsDir && containsString(builtinExcludedDirs, segment) {
+			return true
+		}
+		for _, pattern := range excludePatterns {
+			if matched, _ := matchPathGlob(pattern, prefix); matched {
+				return true
+			}
+		}
+		if useIgnoreFiles && isIgnored(ctx, segments[:i+1], prefixIsDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if the path made of `segments` below the root is ignored by the ignore files in the directories above it.
+// Rules of deeper ignore files take precedence, and the last matching rule of a file wins, like with git.
+func isIgnored(ctx context.Context, segments []string, isDir bool) bool {
+	ignored := false
+	for depth := 0; depth < len(segments); depth++ {
+		dir := filepath.Join(append([]string{gitRoot}, segments[:depth]...)...)
+		relativePath := strings.Join(segments[depth:], "/")
+		for _, rule := range runOf(ctx).ignoreRules.get(dir) {
+			if rule.matches(relativePath, isDir) {
+				ignored = !rule.negated
+			}
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/hashicorp/hcl/v2"
+	log "github.com/sirupsen/logrus"
+)
+
+// A config that could not be turned into a project, or whose dependencies could not be cascaded
+type moduleFailure struct {
+	// The absolute path of the config that failed
+	configPath string
+
+	// Where in the config the error is, if the error came from HCL diagnostics
+	rng *hcl.Range
+
+	// What went wrong
+	err error
+}
+
+// Collects failures from concurrent project generation, keeping only the first failure of each config
+type failureCollector struct {
+	mtx      sync.Mutex
+	failures map[string]moduleFailure
+}
+
+func newFailureCollector() *failureCollector {
+	return &failureCollector{failures: map[string]moduleFailure{}}
+}
+
+// Records a failure for the config at `path`, returning false if that config already failed
+func (c *failureCollector) add(path string, err error) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if _, ok := c.failures[path]; ok {
+		return false
+	}
+	c.failures[path] = moduleFailure{configPath: path, rng: diagnosticRange(err), err: err}
+	return true
+}
+
+// Returns the failures sorted by config path
+func (c *failureCollector) list() []moduleFailure {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	failures := make([]moduleFailure, 0, len(c.failures))
+	for _, failure := range c.failures {
+		failures = append(failures, failure)
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].configPath < failures[j].configPath })
+	return failures
+}
+
+// Returns the range of the first HCL diagnostic with a subject in the chain of `err`, if any
+func diagnosticRange(err error) *hcl.Range {
+	var diags hcl.Diagnostics
+	if goerrors.As(err, &diags) {
+		for _, diag := range diags {
+			if diag.Subject != nil {
+				rng := *diag.Subject
+				return &rng
+			}
+		}
+	}
+
+	var diag *hcl.Diagnostic
+	if goerrors.As(err, &diag) && diag.Subject != nil {
+		rng := *diag.Subject
+		return &rng
+	}
+
+	return nil
+}
+
+// Handles an error creating the project for the config at `path`. With `--keep-going` the error is
+// recorded so the other projects can still be generated, otherwise it is returned as is.
+func handleProjectError(ctx context.Context, path string, err error) error {
+	if !keepGoing {
+		reportErrorDiagnostic(ctx, ruleProjectFailed, path, err)
+		return err
+	}
+
+	if runOf(ctx).failures.add(path, err) {
+		reportErrorDiagnostic(ctx, ruleProjectFailed, path, err)
+		log.Error("Failed to create project for ", path, ": ", err)
+	}
+	return nil
+}
+
+// Reports a config that could not be parsed while cascading the dependencies of another module. The
+// module's project is still created, just without the dependencies of that config.
+func reportCascadeFailure(ctx context.Context, path string, dependent string, err error) {
+	if runOf(ctx).failures.add(path, err) {
+		reportErrorDiagnostic(ctx, ruleUnparseableConfig, path, err)
+		log.Debug("Could not cascade dependencies of ", path, " into ", dependent)
+	}
+}
+
+// Checks if the path of a dependency is a terragrunt config that should be parsable, rather than a glob
+// or a plain file
+func isConfigFile(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return strings.HasSuffix(path, ".hcl") || strings.HasSuffix(path, ".hcl.json") || isConfigFilename(filepath.Base(path))
+}
+
+// Prints a table of failures, with each config relative to the root and the range of the error in it
+func printFailureSummary(out io.Writer, failures []moduleFailure) {
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "CONFIG\tRANGE\tERROR")
+	for _, failure := range failures {
+		location := "-"
+		if failure.rng != nil {
+			location = fmt.Sprintf("%d:%d-%d:%d", failure.rng.Start.Line, failure.rng.Start.Column, failure.rng.End.Line, failure.rng.End.Column)
+		}
+
+		configPath := failure.configPath
+		if relative, err := filepath.Rel(gitRoot, configPath); err == nil {
+			configPath = filepath.ToSlash(relative)
+		}
+
+		message := strings.Join(strings.Fields(failure.err.Error()), " ")
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", configPath, location, message)
+	}
+	writer.Flush()
+}
+
+// Returned when some projects could not be generated with `--keep-going`, while the others were written
+type partialFailureError struct {
+	failed int
+}
+
+func (e *partialFailureError) Error() string {
+	return fmt.Sprintf("failed to generate %d config(s), the projects of all other configs were generated", e.failed)
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// The most combinations of feature flag values evaluated for a single config with `--feature-matrix`
+const maxFeatureCombinations = 64
+
+// Parses the `--feature name=value` overrides. A flag can only be given more than once with `--feature-matrix`.
+func parseFeatureOverrides(values []string) (map[string][]string, error) {
+	overrides := map[string][]string{}
+	for _, pair := range values {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid --feature %q, expected name=value", pair)
+		}
+		if len(overrides[name]) > 0 && !featureMatrix {
+			return nil, fmt.Errorf("feature %q is given more than once, which needs --feature-matrix", name)
+		}
+		if !containsString(overrides[name], value) {
+			overrides[name] = append(overrides[name], value)
+		}
+	}
+	return overrides, nil
+}
+
+// Sets the feature flags of `opts` to the first value of each `--feature` override of the run of `ctx`, then
+// to `values`
+func applyFeatureFlags(ctx context.Context, opts *options.TerragruntOptions, values map[string]string) {
+	for name, overrides := range runOf(ctx).featureOverrides {
+		opts.FeatureFlags.Store(name, overrides[0])
+	}
+	for name, value := range values {
+		opts.FeatureFlags.Store(name, value)
+	}
+}
+
+// Returns a key for the feature flag values of `opts`, which is empty when no flag is set
+func featureKey(opts *options.TerragruntOptions) string {
+	if opts.FeatureFlags == nil || opts.FeatureFlags.Size() == 0 {
+		return ""
+	}
+
+	pairs := []string{}
+	opts.FeatureFlags.Range(func(name, value string) bool {
+		pairs = append(pairs, name+"="+value)
+		return true
+	})
+	sort.Strings(pairs)
+	return "|" + strings.Join(pairs, ",")
+}
+
+// Returns every combination of the values of the feature flags declared by the config at `path` and the configs
+// it includes. The values of a flag are its default, its `--feature` overrides, and both values of bool flags.
+func featureCombinations(ctx *config.ParsingContext, path string) ([]map[string]string, error) {
+	parsedConfig, err := partialParseConfigChain(ctx.WithDecodeList(config.FeatureFlagsBlock), path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	combinations := []map[string]string{{}}
+	for _, flag := range parsedConfig.FeatureFlags {
+		defaultValue, err := flag.DefaultAsString()
+		if err != nil {
+			return nil, err
+		}
+		values := []string{defaultValue}
+		if flag.Default != nil && flag.Default.Type() == cty.Bool {
+			values = []string{"true", "false"}
+		}
+		for _, value := range runOf(ctx).featureOverrides[flag.Name] {
+			if !containsString(values, value) {
+				values = append(values, value)
+			}
+		}
+
+		expanded := []map[string]string{}
+		for _, combination := range combinations {
+			for _, value := range values {
+				next := map[string]string{flag.Name: value}
+				for name, otherValue := range combination {
+					next[name] = otherValue
+				}
+				expanded = append(expanded, next)
+			}
+		}
+		if len(expanded) > maxFeatureCombinations {
+			return nil, fmt.Errorf("%s: the values of its feature flags make more than %d combinations", path, maxFeatureCombinations)
+		}
+		combinations = expanded
+	}
+	return combinations, nil
+}
+
+// Adds the dependencies the config at `path` has with every combination of its feature flag values to
+// `dependencies`, so its project is planned whichever way the flags are set
+func withFeatureMatrixDependencies(ctx context.Context, path string, dependencies []string) ([]string, error) {
+	opts, err := options.NewTerragruntOptionsWithConfigPath(path)
+	if err != nil {
+		return nil, err
+	}
+	opts.OriginalTerragruntConfigPath = path
+	opts.Env = getEnvs(ctx)
+	applyFeatureFlags(ctx, opts, nil)
+
+	combinations, err := featureCombinations(newParsingContext(ctx, opts), path)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, combination := range combinations {
+		opts, err := options.NewTerragruntOptionsWithConfigPath(path)
+		if err != nil {
+			return nil, err
+		}
+		opts.OriginalTerragruntConfigPath = path
+		opts.Env = getEnvs(ctx)
+		applyFeatureFlags(ctx, opts, combination)
+
+		parsingContext := newParsingContext(ctx, opts)
+		direct, err := getDirectDependencies(parsingContext, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate %s with feature flags %s: %w", path, strings.TrimPrefix(featureKey(opts), "|"), err)
+		}
+		for _, dependency := range withCascadedDependencies(parsingContext, path, direct) {
+			if !containsString(dependencies, dependency) {
+				dependencies = append(dependencies, dependency)
+			}
+		}
+	}
+	return dependencies, nil
+}
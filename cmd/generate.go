@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"github.com/gruntwork-io/terragrunt/util"
 	"regexp"
 	"sort"
 
@@ -13,34 +12,30 @@ import (
 	"github.com/gruntwork-io/terragrunt/options"
 	"github.com/spf13/cobra"
 
-	"golang.org/x/sync/errgroup"
-	"golang.org/x/sync/semaphore"
-	"golang.org/x/sync/singleflight"
-
 	"context"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 )
 
-// Parse env vars into a map
-func getEnvs() map[string]string {
-	envs := os.Environ()
-	m := make(map[string]string)
-
-	for _, env := range envs {
-		results := strings.SplitN(env, "=", 2)
-		m[results[0]] = results[1]
+// Returns a copy of the environment `get_env` sees in the run of `ctx`, see `loadEnv`
+func getEnvs(ctx context.Context) map[string]string {
+	env := runOf(ctx).env
+	m := make(map[string]string, len(env))
+	for key, value := range env {
+		m[key] = value
 	}
 
 	return m
 }
 
 // Terragrunt imports can be relative or absolute
-// This makes relative paths absolute
+// This makes relative paths absolute, and maps absolute paths under the real root back into the root
 func makePathAbsolute(path string, parentPath string) string {
+	path = toLinkRoot(path)
 	if strings.HasPrefix(path, filepath.ToSlash(gitRoot)) {
 		return path
 	}
@@ -49,12 +44,16 @@ func makePathAbsolute(path string, parentPath string) string {
 	return filepath.Join(parentDir, path)
 }
 
-var requestGroup singleflight.Group
-
-// Set up a cache for the getDependencies function
+// Set up a cache for the getDirectDependencies function
 type getDependenciesOutput struct {
-	dependencies []string
+	// If set, the config is a parent that should not get a project of its own
+	parent       bool
+	dependencies []dependency
+	cascade      CascadeLocals
 	err          error
+
+	// If set, the error happened after the context of the unit evaluating the config was done
+	cancelled bool
 }
 
 type GetDependenciesCache struct {
@@ -79,8 +78,6 @@ func (m *GetDependenciesCache) get(k string) (getDependenciesOutput, bool) {
 	return v, ok
 }
 
-var getDependenciesCache = newGetDependenciesCache()
-
 func uniqueStrings(str []string) []string {
 	keys := make(map[string]bool)
 	list := []string{}
@@ -121,97 +118,200 @@ func sliceUnion(a, b []string) []string {
 	return a
 }
 
-// Parses the terragrunt config at `path` to find all modules it depends on
-func getDependencies(ctx *config.ParsingContext, path string) ([]string, error) {
-	res, err, _ := requestGroup.Do(path, func() (interface{}, error) {
+// Returns the patterns every project's `when_modified` list starts with
+func defaultWhenModified(distribution string) []string {
+	if len(whenModifiedDefaults) > 0 {
+		return append([]string{}, whenModifiedDefaults...)
+	}
+	return append([]string{"*.hcl"}, terraformFileGlobs(distribution)...)
+}
+
+// Returns the `when_modified` exclusions of a project as negated patterns
+func whenModifiedExclusions(locals ResolvedLocals) []string {
+	exclusions := []string{}
+	for _, pattern := range append(append([]string{}, whenModifiedExclude...), locals.WhenModifiedExclude...) {
+		exclusions = append(exclusions, "!"+strings.TrimPrefix(filepath.ToSlash(pattern), "!"))
+	}
+	return exclusions
+}
+
+// Normalizes the `source` of a `terraform` block in the terragrunt config at `path`.
+// Returns the filesystem path of the source and true if it is a local path
+func getLocalTerraformSource(source string, path string) (string, bool, error) {
+	// Use `go-getter` to normalize the source paths
+	parsedSource, err := getter.Detect(source, filepath.Dir(path), getter.Detectors)
+	if err != nil {
+		return "", false, err
+	}
+
+	// Check if the path begins with a drive letter, denoting Windows
+	isWindowsPath, err := regexp.MatchString(`^[A-Za-z]:`, parsedSource)
+	if err != nil {
+		return "", false, err
+	}
+
+	// If the normalized source begins with `file://`, or matched the Windows drive letter check, it is a local path
+	if strings.HasPrefix(parsedSource, "file://") || isWindowsPath {
+		// Remove the prefix so we have a valid filesystem path
+		return strings.TrimPrefix(parsedSource, "file://"), true, nil
+	}
+
+	return "", false, nil
+}
+
+// Finds the terraform version of the terragrunt config at `path` from version pin files and the
+// `required_version` of its local terraform source.
+// Returns the version, or an empty string if none was found, and the pin file it came from, if any
+func getTerraformVersion(ctx *config.ParsingContext, path string, distribution string) (string, string, error) {
+	moduleDirs, err := getTerraformModuleDirs(ctx, path)
+	if err != nil {
+		return "", "", err
+	}
+
+	terraformVersion, pinFile := detectTerraformVersion(ctx, filepath.Dir(path), moduleDirs, distribution)
+	return terraformVersion, pinFile, nil
+}
+
+// Returns the directories whose `required_version` applies to the terragrunt config at `path`: its own
+// directory, and its terraform source when that is local
+func getTerraformModuleDirs(ctx *config.ParsingContext, path string) ([]string, error) {
+	moduleDirs := []string{filepath.Dir(path)}
+
+	parseCtx := newParsingContext(ctx, ctx.TerragruntOptions).WithDecodeList(config.TerraformBlock)
+	parsedConfig, err := partialParseConfigChain(parseCtx, path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if parsedConfig.Terraform != nil && parsedConfig.Terraform.Source != nil {
+		parsedSource, isLocal, err := getLocalTerraformSource(*parsedConfig.Terraform.Source, path)
+		if err != nil {
+			return nil, err
+		}
+		if isLocal {
+			moduleDirs = append(moduleDirs, parsedSource)
+		}
+	}
+
+	return moduleDirs, nil
+}
+
+// Parses the terragrunt config at `path` to find all modules it directly depends on, along with
+// the kind of each dependency and the module's cascading settings
+func getDirectDependencies(ctx *config.ParsingContext, path string) (getDependenciesOutput, error) {
+	// The distribution decides which files of a local source are dependencies. Rules sit below the locals in
+	// precedence, so the distribution of a rule is resolved here and the locals are applied once parsed.
+	relativeDir, err := filepath.Rel(gitRoot, filepath.Dir(path))
+	if err != nil {
+		return getDependenciesOutput{}, err
+	}
+	ruleDistribution := resolveProjectRules(ctx, filepath.ToSlash(relativeDir)).TerraformDistribution
+
+	// Feature flags can change the dependencies, so configs are cached for each set of flag values and rule
+	// distribution
+	cacheKey := path + featureKey(ctx.TerragruntOptions)
+	if ruleDistribution != "" {
+		cacheKey += "|distribution=" + ruleDistribution
+	}
+	run := runOf(ctx)
+	// Errors computing the dependencies after `ctx` is done are likely caused by the unit being cut short, so
+	// they are not cached
+	cache := func(output getDependenciesOutput) {
+		if output.err == nil || ctx.Err() == nil {
+			run.dependencies.set(cacheKey, output)
+		}
+	}
+
+	evaluate := func() (getDependenciesOutput, error) {
 		// Check if this path has already been computed
-		cachedResult, ok := getDependenciesCache.get(path)
+		cachedResult, ok := run.dependencies.get(cacheKey)
 		if ok {
-			return cachedResult.dependencies, cachedResult.err
+			return cachedResult, cachedResult.err
 		}
 
 		// parse the module path to find what it includes, as well as its potential to be a parent
 		// return nils to indicate we should skip this project
 		isParent, includes, err := parseModule(ctx, path)
 		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-			return nil, err
+			cache(getDependenciesOutput{err: err})
+			return getDependenciesOutput{}, err
 		}
-		if isParent && ignoreParentTerragrunt {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, nil})
-			return nil, nil
+		// Parents without includes have nothing to cascade, and often can't be parsed on their own. Parents
+		// that include other configs, like `_envcommon` files, still pass on the dependencies they include.
+		skipProject := isParent && ignoreParentTerragrunt
+		if skipProject && len(includes) == 0 {
+			cache(getDependenciesOutput{parent: true})
+			return getDependenciesOutput{parent: true}, nil
 		}
 
-		dependencies := []string{}
-		if len(includes) > 0 {
-			for _, includeDep := range includes {
-				getDependenciesCache.set(includeDep.Path, getDependenciesOutput{nil, err})
-				dependencies = append(dependencies, includeDep.Path)
-			}
+		dependencies := []dependency{}
+		for _, includeDep := range includes {
+			dependencies = append(dependencies, dependency{includeDep.Path, dependencyKindInclude})
 		}
 
 		// Parse the HCL file
-		parseCtx := config.NewParsingContext(ctx, ctx.TerragruntOptions).
+		parseCtx := newParsingContext(ctx, ctx.TerragruntOptions).
 			WithDecodeList(
 				config.DependencyBlock,
 				config.DependenciesBlock,
 				config.TerraformBlock,
 			)
-		parsedConfig, err := config.PartialParseConfigFile(parseCtx, path, nil)
+		parsedConfig, err := partialParseConfigChain(parseCtx, path, nil)
 		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-			return nil, err
+			cache(getDependenciesOutput{err: err})
+			return getDependenciesOutput{}, err
 		}
 
 		// Parse out locals
 		locals, err := parseLocals(ctx, path, nil)
 		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-			return nil, err
+			cache(getDependenciesOutput{err: err})
+			return getDependenciesOutput{}, err
+		}
+
+		distribution := defaultDistribution
+		if ruleDistribution != "" {
+			distribution = ruleDistribution
+		}
+		if locals.TerraformDistribution != "" {
+			distribution = locals.TerraformDistribution
 		}
 
 		// Get deps from locals
-		if locals.ExtraAtlantisDependencies != nil {
-			dependencies = sliceUnion(dependencies, locals.ExtraAtlantisDependencies)
+		for _, extraDep := range locals.ExtraAtlantisDependencies {
+			if !containsDependency(dependencies, extraDep) {
+				dependencies = append(dependencies, dependency{extraDep, dependencyKindExtra})
+			}
 		}
 
 		// Get deps from `dependencies` and `dependency` blocks
 		if parsedConfig.Dependencies != nil && !ignoreDependencyBlocks {
 			for _, parsedPaths := range parsedConfig.Dependencies.Paths {
-				dependencies = append(dependencies, filepath.Join(parsedPaths, "terragrunt.hcl"))
+				dependencies = append(dependencies, dependency{configFileInDir(parsedPaths, path), dependencyKindDependency})
 			}
 		}
 
 		// Get deps from the `Source` field of the `Terraform` block
 		if parsedConfig.Terraform != nil && parsedConfig.Terraform.Source != nil {
-			source := parsedConfig.Terraform.Source
-
-			// Use `go-getter` to normalize the source paths
-			parsedSource, err := getter.Detect(*source, filepath.Dir(path), getter.Detectors)
+			parsedSource, isLocal, err := getLocalTerraformSource(*parsedConfig.Terraform.Source, path)
 			if err != nil {
-				return nil, err
+				return getDependenciesOutput{}, err
 			}
 
-			// Check if the path begins with a drive letter, denoting Windows
-			isWindowsPath, err := regexp.MatchString(`^[A-Za-z]:`, parsedSource)
-			if err != nil {
-				return nil, err
-			}
-
-			// If the normalized source begins with `file://`, or matched the Windows drive letter check, it is a local path
-			if strings.HasPrefix(parsedSource, "file://") || isWindowsPath {
-				// Remove the prefix so we have a valid filesystem path
-				parsedSource = strings.TrimPrefix(parsedSource, "file://")
-
-				dependencies = append(dependencies, filepath.Join(parsedSource, "*.tf*"))
+			if isLocal {
+				for _, glob := range terraformFileGlobs(distribution) {
+					dependencies = append(dependencies, dependency{filepath.Join(parsedSource, glob), dependencyKindSource})
+				}
 
-				ls, err := parseTerraformLocalModuleSource(parsedSource)
+				ls, err := parseTerraformLocalModuleSource(ctx, parsedSource, distribution)
 				if err != nil {
-					return nil, err
+					return getDependenciesOutput{}, err
 				}
 				sort.Strings(ls)
 
-				dependencies = append(dependencies, ls...)
+				for _, localModule := range ls {
+					dependencies = append(dependencies, dependency{localModule, dependencyKindSource})
+				}
 			}
 		}
 
@@ -219,104 +319,114 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 		if parsedConfig.Terraform != nil && parsedConfig.Terraform.ExtraArgs != nil {
 			extraArgs := parsedConfig.Terraform.ExtraArgs
 			for _, arg := range extraArgs {
+				varFiles := []string{}
 				if arg.RequiredVarFiles != nil {
-					dependencies = append(dependencies, *arg.RequiredVarFiles...)
+					varFiles = append(varFiles, *arg.RequiredVarFiles...)
 				}
 				if arg.OptionalVarFiles != nil {
-					dependencies = append(dependencies, *arg.OptionalVarFiles...)
+					varFiles = append(varFiles, *arg.OptionalVarFiles...)
 				}
 				if arg.Arguments != nil {
 					for _, cliFlag := range *arg.Arguments {
 						if strings.HasPrefix(cliFlag, "-var-file=") {
-							dependencies = append(dependencies, strings.TrimPrefix(cliFlag, "-var-file="))
+							varFiles = append(varFiles, strings.TrimPrefix(cliFlag, "-var-file="))
 						}
 					}
 				}
+				for _, varFile := range varFiles {
+					dependencies = append(dependencies, dependency{varFile, dependencyKindVarFile})
+				}
 			}
 		}
 
-		// Filter out and dependencies that are the empty string
-		nonEmptyDeps := []string{}
+		// Filter out and dependencies that are the empty string or excluded
+		nonEmptyDeps := []dependency{}
 		for _, dep := range dependencies {
-			if dep != "" {
-				childDepAbsPath := dep
+			if dep.path != "" {
+				childDepAbsPath := dep.path
 				if !filepath.IsAbs(childDepAbsPath) {
-					childDepAbsPath = makePathAbsolute(dep, path)
-				}
-				childDepAbsPath = filepath.ToSlash(childDepAbsPath)
-				nonEmptyDeps = append(nonEmptyDeps, childDepAbsPath)
-			}
-		}
-
-		// Recurse to find dependencies of all dependencies
-		cascadedDeps := []string{}
-		for _, dep := range nonEmptyDeps {
-			cascadedDeps = append(cascadedDeps, dep)
-
-			// The "cascading" feature is protected by a flag
-			if !cascadeDependencies {
-				continue
-			}
-
-			depPath := dep
-			terrOpts, _ := options.NewTerragruntOptionsWithConfigPath(depPath)
-			terrOpts.OriginalTerragruntConfigPath = ctx.TerragruntOptions.OriginalTerragruntConfigPath
-			terrOpts.Env = ctx.TerragruntOptions.Env
-			terrContext := config.NewParsingContext(ctx, terrOpts)
-			childDeps, err := getDependencies(terrContext, depPath)
-			if err != nil {
-				continue
-			}
-
-			for _, childDep := range childDeps {
-				// If `childDep` is a relative path, it will be relative to `childDep`, as it is from the nested
-				// `getDependencies` call on the top level module's dependencies. So here we update any relative
-				// path to be from the top level module instead.
-				childDepAbsPath := childDep
-				if !filepath.IsAbs(childDep) {
-					childDepAbsPath, err = filepath.Abs(filepath.Join(depPath, "..", childDep))
-					if err != nil {
-						getDependenciesCache.set(path, getDependenciesOutput{nil, err})
-						return nil, err
-					}
+					childDepAbsPath = makePathAbsolute(dep.path, path)
 				}
-				childDepAbsPath = filepath.ToSlash(childDepAbsPath)
-
-				// Ensure we are not adding a duplicate dependency
-				alreadyExists := false
-				for _, dep := range cascadedDeps {
-					if dep == childDepAbsPath {
-						alreadyExists = true
-						break
-					}
-				}
-				if !alreadyExists {
-					cascadedDeps = append(cascadedDeps, childDepAbsPath)
+				if isExcludedPath(ctx, childDepAbsPath, false) {
+					continue
 				}
+				nonEmptyDeps = append(nonEmptyDeps, dependency{filepath.ToSlash(childDepAbsPath), dep.kind})
 			}
 		}
 
-		if filepath.Base(path) == "terragrunt.hcl" {
+		// Local modules called from terraform files next to the config are part of the module itself
+		if isConfigFilename(filepath.Base(path)) && hasTerraformFiles(ctx, filepath.Dir(path)) {
 			dir := filepath.Dir(path)
 
-			ls, err := parseTerraformLocalModuleSource(dir)
+			ls, err := parseTerraformLocalModuleSource(ctx, dir, distribution)
 			if err != nil {
-				return nil, err
+				return getDependenciesOutput{}, err
 			}
 			sort.Strings(ls)
 
-			cascadedDeps = append(cascadedDeps, ls...)
+			for _, localModule := range ls {
+				nonEmptyDeps = append(nonEmptyDeps, dependency{localModule, dependencyKindSource})
+			}
 		}
 
-		getDependenciesCache.set(path, getDependenciesOutput{cascadedDeps, err})
-		return cascadedDeps, nil
+		output := getDependenciesOutput{parent: skipProject, dependencies: nonEmptyDeps, cascade: locals.Cascade}
+		cache(output)
+		return output, nil
+	}
+
+	res, err, _ := run.requests.Do(cacheKey, func() (interface{}, error) {
+		output, err := evaluate()
+		output.cancelled = err != nil && ctx.Err() != nil
+		return output, err
 	})
 
+	// The config was evaluated by another unit that was cut short, so evaluate it again for this one
+	if err != nil && ctx.Err() == nil && res != nil && res.(getDependenciesOutput).cancelled {
+		return getDirectDependencies(ctx, path)
+	}
+
 	if res != nil {
-		return res.([]string), err
+		return res.(getDependenciesOutput), err
 	} else {
+		return getDependenciesOutput{}, err
+	}
+}
+
+// Parses the terragrunt config at `path` to find all modules it depends on, including the
+// dependencies of its dependencies when cascading is enabled
+func getDependencies(ctx *config.ParsingContext, path string) ([]string, error) {
+	direct, err := getDirectDependencies(ctx, path)
+	if err != nil {
 		return nil, err
 	}
+
+	// Parents don't get projects of their own, which is signaled by returning nil
+	if direct.parent {
+		return nil, nil
+	}
+
+	return withCascadedDependencies(ctx, path, direct), nil
+}
+
+// Adds the dependencies of the direct dependencies of the config at `path` to them, following its cascade settings
+func withCascadedDependencies(ctx *config.ParsingContext, path string, direct getDependenciesOutput) []string {
+	settings := resolveCascadeSettings(direct.cascade)
+	walker := newCascadeWalker(ctx, path, settings)
+
+	// All direct dependencies are always included, the cascade settings only apply to the
+	// dependencies of those dependencies
+	cascadedDeps := []string{}
+	for _, dep := range direct.dependencies {
+		cascadedDeps = append(cascadedDeps, dep.path)
+		childDeps, _ := walker.walk(dep, 1)
+		for _, childDep := range childDeps {
+			if !containsString(cascadedDeps, childDep) {
+				cascadedDeps = append(cascadedDeps, childDep)
+			}
+		}
+	}
+
+	return cascadedDeps
 }
 
 // Creates an AtlantisProject for a directory
@@ -326,18 +436,27 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 		return nil, err
 	}
 	options.OriginalTerragruntConfigPath = sourcePath
-	options.Env = getEnvs()
+	options.Env = getEnvs(ctx)
+	applyFeatureFlags(ctx, options, nil)
 
-	parsingContext := config.NewParsingContext(ctx, options)
-	dependencies, err := getDependencies(parsingContext, sourcePath)
+	parsingContext := newParsingContext(ctx, options)
+	direct, err := getDirectDependencies(parsingContext, sourcePath)
 	if err != nil {
 		return nil, err
 	}
 
-	// dependencies being nil is a sign from `getDependencies` that this project should be skipped
-	if dependencies == nil {
+	isParent := direct.parent || runOf(ctx).parentConfigFiles[sourcePath]
+	if direct.parent && !createParentProject {
+		reportDiagnostic(ctx, ruleParentConfigSkipped, sourcePath, nil, "looks like a parent config and was skipped")
 		return nil, nil
 	}
+	dependencies := withCascadedDependencies(parsingContext, sourcePath, direct)
+	if featureMatrix {
+		dependencies, err = withFeatureMatrixDependencies(ctx, sourcePath, dependencies)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	absoluteSourceDir := filepath.Dir(sourcePath) + string(filepath.Separator)
 	locals, err := parseLocals(parsingContext, sourcePath, nil)
@@ -347,21 +466,70 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 
 	// If `atlantis_skip` is true on the module, then do not produce a project for it
 	if locals.Skip != nil && *locals.Skip {
+		reportDiagnostic(ctx, ruleModuleSkipped, sourcePath, nil, "skipped by atlantis_skip")
 		return nil, nil
 	}
 
+	// Rules sit between the flags and the locals in precedence
+	relativeProjectDir, err := filepath.Rel(gitRoot, filepath.Dir(sourcePath))
+	if err != nil {
+		return nil, err
+	}
+	rule := resolveProjectRules(ctx, filepath.ToSlash(relativeProjectDir))
+
+	distribution := defaultDistribution
+	if rule.TerraformDistribution != "" {
+		distribution = rule.TerraformDistribution
+	}
+	if locals.TerraformDistribution != "" {
+		distribution = locals.TerraformDistribution
+	}
+
+	// A version set by a local or rule takes precedence over the detected one, which takes precedence over the flag
+	terraformVersion := defaultTerraformVersion
+	if locals.TerraformVersion != "" {
+		terraformVersion = locals.TerraformVersion
+	} else if rule.TerraformVersion != "" {
+		terraformVersion = rule.TerraformVersion
+	} else if detectTerraformVersions {
+		detectedVersion, pinFile, err := getTerraformVersion(parsingContext, sourcePath, distribution)
+		if err != nil {
+			return nil, err
+		}
+		if detectedVersion != "" {
+			terraformVersion = detectedVersion
+		}
+		if pinFile != "" {
+			dependencies = append(dependencies, filepath.ToSlash(pinFile))
+		}
+	}
+
+	for _, sidecar := range locals.SidecarFiles {
+		dependencies = append(dependencies, filepath.ToSlash(sidecar))
+	}
+
 	// All dependencies depend on their own .hcl file, and any tf files in their directory
-	relativeDependencies := []string{
-		"*.hcl",
-		"*.tf*",
+	relativeDependencies := defaultWhenModified(distribution)
+
+	// Parents always depend on their own file, even when the defaults don't cover it
+	if isParent && !matchesAnyPattern(relativeDependencies, filepath.Base(sourcePath)) {
+		relativeDependencies = append(relativeDependencies, filepath.Base(sourcePath))
+	}
+
+	// Configs found through a symlinked directory change in git under their real directory
+	if realDir := realConfigDir(sourcePath); realDir != "" {
+		relativeRealDir, err := filepath.Rel(absoluteSourceDir, realDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, pattern := range defaultWhenModified(distribution) {
+			relativeDependencies = append(relativeDependencies, filepath.ToSlash(filepath.Join(relativeRealDir, pattern)))
+		}
 	}
 
 	// Add other dependencies based on their relative paths. We always want to output with Unix path separators
 	for _, dependencyPath := range dependencies {
-		absolutePath := dependencyPath
-		if !filepath.IsAbs(absolutePath) {
-			absolutePath = makePathAbsolute(dependencyPath, sourcePath)
-		}
+		absolutePath := dependencyAbsolutePath(dependencyPath, sourcePath)
 		relativePath, err := filepath.Rel(absoluteSourceDir, absolutePath)
 		if err != nil {
 			return nil, err
@@ -370,6 +538,10 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 		relativeDependencies = append(relativeDependencies, filepath.ToSlash(relativePath))
 	}
 
+	for _, pattern := range locals.WhenModifiedExtra {
+		relativeDependencies = append(relativeDependencies, filepath.ToSlash(pattern))
+	}
+
 	// Clean up the relative path to the format Atlantis expects
 	relativeSourceDir := strings.TrimPrefix(absoluteSourceDir, gitRoot)
 	relativeSourceDir = strings.TrimSuffix(relativeSourceDir, string(filepath.Separator))
@@ -378,6 +550,9 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 	}
 
 	workflow := defaultWorkflow
+	if rule.Workflow != "" {
+		workflow = rule.Workflow
+	}
 	if locals.AtlantisWorkflow != "" {
 		workflow = locals.AtlantisWorkflow
 	}
@@ -386,28 +561,32 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 	if len(defaultApplyRequirements) == 0 {
 		applyRequirements = nil
 	}
+	if rule.ApplyRequirements != nil {
+		applyRequirements = rule.ApplyRequirements
+	}
 	if locals.ApplyRequirements != nil {
 		applyRequirements = &locals.ApplyRequirements
 	}
 
-	resolvedAutoPlan := autoPlan
+	// Parents are planned on demand unless a rule or local says otherwise, since a change to them also
+	// plans every module including them
+	resolvedAutoPlan := autoPlan && !isParent
+	if rule.Autoplan != nil {
+		resolvedAutoPlan = *rule.Autoplan
+	}
 	if locals.AutoPlan != nil {
 		resolvedAutoPlan = *locals.AutoPlan
 	}
 
-	terraformVersion := defaultTerraformVersion
-	if locals.TerraformVersion != "" {
-		terraformVersion = locals.TerraformVersion
-	}
-
 	project := &AtlantisProject{
-		Dir:               filepath.ToSlash(relativeSourceDir),
-		Workflow:          workflow,
-		TerraformVersion:  terraformVersion,
-		ApplyRequirements: applyRequirements,
+		Dir:                   filepath.ToSlash(relativeSourceDir),
+		Workflow:              workflow,
+		TerraformVersion:      terraformVersion,
+		TerraformDistribution: distribution,
+		ApplyRequirements:     applyRequirements,
 		Autoplan: AutoplanConfig{
 			Enabled:      resolvedAutoPlan,
-			WhenModified: uniqueStrings(relativeDependencies),
+			WhenModified: uniqueStrings(append(relativeDependencies, whenModifiedExclusions(locals)...)),
 		},
 	}
 
@@ -420,6 +599,14 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 	regex := regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
 	projectName := regex.ReplaceAllString(project.Dir, "_")
 
+	// Parents like root.hcl or _envcommon/vpc.hcl can share their directory with other projects, so they
+	// are always named after their file to keep projects unique
+	if isParent && !isConfigFilename(filepath.Base(sourcePath)) {
+		relativeSourcePath := strings.TrimSuffix(strings.TrimPrefix(sourcePath, gitRoot), filepath.Ext(sourcePath))
+		projectName = regex.ReplaceAllString(filepath.ToSlash(relativeSourcePath), "_")
+		project.Name = projectName
+	}
+
 	if createProjectName {
 		project.Name = projectName
 	}
@@ -438,15 +625,17 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 	applyRequirements := &defaultApplyRequirements
 	resolvedAutoPlan := autoPlan
 	terraformVersion := defaultTerraformVersion
+	distribution := defaultDistribution
 
 	projectHclFile := filepath.Join(workingDir, projectHcl)
 	projectHclOptions, err := options.NewTerragruntOptionsWithConfigPath(workingDir)
 	if err != nil {
 		return nil, err
 	}
-	projectHclOptions.Env = getEnvs()
+	projectHclOptions.Env = getEnvs(ctx)
+	applyFeatureFlags(ctx, projectHclOptions, nil)
 
-	parsingContext := config.NewParsingContext(ctx, projectHclOptions)
+	parsingContext := newParsingContext(ctx, projectHclOptions)
 	locals, err := parseLocals(parsingContext, projectHclFile, nil)
 	if err != nil {
 		return nil, err
@@ -454,6 +643,7 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 
 	// If `atlantis_skip` is true on the module, then do not produce a project for it
 	if locals.Skip != nil && *locals.Skip {
+		reportDiagnostic(ctx, ruleModuleSkipped, projectHclFile, nil, "skipped by atlantis_skip")
 		return nil, nil
 	}
 
@@ -476,6 +666,28 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 		}
 	}
 
+	for _, sidecar := range locals.SidecarFiles {
+		relSidecar, err := filepath.Rel(workingDir, sidecar)
+		if err != nil {
+			return nil, err
+		}
+		projectHclDependencies = append(projectHclDependencies, filepath.ToSlash(relSidecar))
+	}
+
+	for _, pattern := range locals.WhenModifiedExtra {
+		projectHclDependencies = append(projectHclDependencies, filepath.ToSlash(pattern))
+	}
+
+	// Rules sit between the flags and the locals in precedence
+	dir, err := filepath.Rel(gitRoot, workingDir)
+	if err != nil {
+		return nil, err
+	}
+	rule := resolveProjectRules(ctx, filepath.ToSlash(dir))
+
+	if rule.Workflow != "" {
+		workflow = rule.Workflow
+	}
 	if locals.AtlantisWorkflow != "" {
 		workflow = locals.AtlantisWorkflow
 	}
@@ -483,26 +695,49 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 	if len(defaultApplyRequirements) == 0 {
 		applyRequirements = nil
 	}
+	if rule.ApplyRequirements != nil {
+		applyRequirements = rule.ApplyRequirements
+	}
 	if locals.ApplyRequirements != nil {
 		applyRequirements = &locals.ApplyRequirements
 	}
 
+	if rule.Autoplan != nil {
+		resolvedAutoPlan = *rule.Autoplan
+	}
 	if locals.AutoPlan != nil {
 		resolvedAutoPlan = *locals.AutoPlan
 	}
 
+	if rule.TerraformDistribution != "" {
+		distribution = rule.TerraformDistribution
+	}
+	if locals.TerraformDistribution != "" {
+		distribution = locals.TerraformDistribution
+	}
+
+	// A version set by a local or rule takes precedence over the detected one, which takes precedence over the flag
+	detectVersion := false
 	if locals.TerraformVersion != "" {
 		terraformVersion = locals.TerraformVersion
+	} else if rule.TerraformVersion != "" {
+		terraformVersion = rule.TerraformVersion
+	} else {
+		detectVersion = detectTerraformVersions
 	}
 
+	// The `required_version` of every child's module applies to the project
+	moduleDirs := []string{}
+
 	// build dependencies for terragrunt childs in directories below project hcl file
 	for _, sourcePath := range sourcePaths {
 		opt, err := options.NewTerragruntOptionsWithConfigPath(sourcePath)
 		if err != nil {
 			return nil, err
 		}
-		opt.Env = getEnvs()
-		parsingContext := config.NewParsingContext(ctx, opt)
+		opt.Env = getEnvs(ctx)
+		applyFeatureFlags(ctx, opt, nil)
+		parsingContext := newParsingContext(ctx, opt)
 		dependencies, err := getDependencies(parsingContext, sourcePath)
 		if err != nil {
 			return nil, err
@@ -512,20 +747,23 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 			return nil, nil
 		}
 
+		if detectVersion {
+			childModuleDirs, err := getTerraformModuleDirs(parsingContext, sourcePath)
+			if err != nil {
+				return nil, err
+			}
+			moduleDirs = append(moduleDirs, childModuleDirs...)
+		}
+
 		// All dependencies depend on their own .hcl file, and any tf files in their directory
-		relativeDependencies := []string{
-			"*.hcl",
-			"*.tf*",
-			"**/*.hcl",
-			"**/*.tf*",
+		relativeDependencies := defaultWhenModified(distribution)
+		for _, pattern := range defaultWhenModified(distribution) {
+			relativeDependencies = append(relativeDependencies, "**/"+pattern)
 		}
 
 		// Add other dependencies based on their relative paths. We always want to output with Unix path separators
 		for _, dependencyPath := range dependencies {
-			absolutePath := dependencyPath
-			if !filepath.IsAbs(absolutePath) {
-				absolutePath = makePathAbsolute(dependencyPath, sourcePath)
-			}
+			absolutePath := dependencyAbsolutePath(dependencyPath, sourcePath)
 
 			relativePath, err := filepath.Rel(workingDir, absolutePath)
 			if err != nil {
@@ -539,19 +777,30 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 
 		childDependencies = append(childDependencies, relativeDependencies...)
 	}
-	dir, err := filepath.Rel(gitRoot, workingDir)
-	if err != nil {
-		return nil, err
+
+	if detectVersion {
+		detectedVersion, pinFile := detectTerraformVersion(ctx, workingDir, moduleDirs, distribution)
+		if detectedVersion != "" {
+			terraformVersion = detectedVersion
+		}
+		if pinFile != "" {
+			relPinFile, err := filepath.Rel(workingDir, pinFile)
+			if err != nil {
+				return nil, err
+			}
+			projectHclDependencies = append(projectHclDependencies, filepath.ToSlash(relPinFile))
+		}
 	}
 
 	project := &AtlantisProject{
-		Dir:               filepath.ToSlash(dir),
-		Workflow:          workflow,
-		TerraformVersion:  terraformVersion,
-		ApplyRequirements: applyRequirements,
+		Dir:                   filepath.ToSlash(dir),
+		Workflow:              workflow,
+		TerraformVersion:      terraformVersion,
+		TerraformDistribution: distribution,
+		ApplyRequirements:     applyRequirements,
 		Autoplan: AutoplanConfig{
 			Enabled:      resolvedAutoPlan,
-			WhenModified: uniqueStrings(append(childDependencies, projectHclDependencies...)),
+			WhenModified: uniqueStrings(append(append(childDependencies, projectHclDependencies...), whenModifiedExclusions(locals)...)),
 		},
 	}
 
@@ -576,7 +825,7 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 }
 
 // Finds the absolute paths of all terragrunt.hcl files
-func getAllTerragruntFiles(path string) ([]string, error) {
+func getAllTerragruntFiles(ctx context.Context, path string) ([]string, error) {
 	options, err := options.NewTerragruntOptionsWithConfigPath(path)
 	if err != nil {
 		return nil, err
@@ -603,7 +852,7 @@ func getAllTerragruntFiles(path string) ([]string, error) {
 	uniqueConfigFilePaths := make(map[string]bool)
 	orderedConfigFilePaths := []string{}
 	for _, workingPath := range workingPaths {
-		paths, err := FindConfigFilesInPath(workingPath, options)
+		paths, err := FindConfigFilesInPath(ctx, workingPath, options)
 		if err != nil {
 			return nil, err
 		}
@@ -629,89 +878,90 @@ func getAllTerragruntFiles(path string) ([]string, error) {
 }
 
 // FindConfigFilesInPath returns a list of all Terragrunt config files in the given path or any subfolder of the path. A file is a Terragrunt
-// config file if it has a name as returned by the DefaultConfigPath method
-func FindConfigFilesInPath(rootPath string, opts *options.TerragruntOptions) ([]string, error) {
-	configFiles := []string{}
-
-	walkFunc := filepath.Walk
-
-	err := walkFunc(rootPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if !info.IsDir() {
-			return nil
-		}
-
-		for _, configFile := range []string{"root.hcl"} {
-			if !filepath.IsAbs(configFile) {
-				configFile = util.JoinPath(path, configFile)
-			}
-
-			if !util.IsDir(configFile) && util.FileExists(configFile) {
-				configFiles = append(configFiles, configFile)
-				break
-			}
-		}
-
-		return nil
-	})
-
-	nestedConfigFiles, err := config.FindConfigFilesInPath(rootPath, opts)
-	if err == nil {
-		configFiles = append(configFiles, nestedConfigFiles...)
+// config file if it is named root.hcl, or has one of the names given by `--config-filename`. Excluded paths are skipped,
+// see `isExcludedPath`
+func FindConfigFilesInPath(ctx context.Context, rootPath string, opts *options.TerragruntOptions) ([]string, error) {
+	absoluteRootPath, err := filepath.Abs(rootPath)
+	if err != nil {
+		return nil, err
 	}
-	return configFiles, nil
+	index, err := repositoryIndexFor(ctx, absoluteRootPath)
+	if err != nil {
+		return nil, err
+	}
+	return index.configFilesIn(absoluteRootPath), nil
 }
 
 // Finds the absolute paths of all arbitrary project hcl files
-func getAllTerragruntProjectHclFiles() map[string][]string {
-	projectHclFiles := projectHclFiles
-	orderedHclFilePaths := map[string][]string{}
+func getAllTerragruntProjectHclFiles(ctx context.Context) map[string][]string {
 	uniqueHclFileAbsPaths := map[string][]string{}
 	for _, projectHclFile := range projectHclFiles {
-		err := filepath.Walk(gitRoot, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			if !info.IsDir() && info.Name() == projectHclFile {
-				orderedHclFilePaths[projectHclFile] = append(orderedHclFilePaths[projectHclFile], filepath.Dir(path))
-			}
-
-			return nil
-		})
-
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		for _, uniquePath := range orderedHclFilePaths[projectHclFile] {
-			uniqueAbsPath, err := filepath.Abs(uniquePath)
-			if err != nil {
-				return nil
-			}
-			uniqueHclFileAbsPaths[projectHclFile] = append(uniqueHclFileAbsPaths[projectHclFile], uniqueAbsPath)
-		}
+		uniqueHclFileAbsPaths[projectHclFile] = runOf(ctx).index.dirsWithFile(projectHclFile)
 	}
 	return uniqueHclFileAbsPaths
 }
 
-func main(cmd *cobra.Command, args []string) error {
+func main(ctx context.Context, cmd *cobra.Command, args []string) error {
+	ctx, cancel := generationContext(ctx)
+	defer cancel()
+
 	// Ensure the gitRoot has a trailing slash and is an absolute path
 	absoluteGitRoot, err := filepath.Abs(gitRoot)
 	if err != nil {
 		return err
 	}
 	gitRoot = absoluteGitRoot + string(filepath.Separator)
+	resolveRealGitRoot()
+	if err := validateDistribution(defaultDistribution); err != nil {
+		return err
+	}
+	if err := validateCascadeEdges(cascadeEdges); err != nil {
+		return err
+	}
+	if err := validateCascadeDepth(cascadeDepth); err != nil {
+		return err
+	}
+	if err := validateMergeStrategies(mergeStrategies); err != nil {
+		return err
+	}
+	if err := validateExcludePatterns(excludePatterns); err != nil {
+		return err
+	}
+	if err := validateSandboxValues(sandboxValues); err != nil {
+		return err
+	}
+	if err := loadRunState(ctx, sandbox); err != nil {
+		return err
+	}
+	run := runOf(ctx)
+	run.rules, err = readProjectRules(rulesFile)
+	if err != nil {
+		return err
+	}
+	if (parentWhenIncluded || createParentProject) && ignoreParentTerragrunt {
+		run.includedConfigs, err = findIncludedConfigs(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if createParentProject && ignoreParentTerragrunt {
+		run.parentConfigFiles, err = findParentConfigFiles(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if strict {
+		if err := lintStrict(ctx); err != nil {
+			return err
+		}
+	}
 	workingDirs := []string{gitRoot}
 	projectHclDirMap := map[string][]string{}
 	var projectHclDirs []string
 	if len(projectHclFiles) > 0 {
 		workingDirs = nil
 		// map [project-hcl-file] => directories containing project-hcl-file
-		projectHclDirMap = getAllTerragruntProjectHclFiles()
+		projectHclDirMap = getAllTerragruntProjectHclFiles(ctx)
 		for _, projectHclFile := range projectHclFiles {
 			projectHclDirs = append(projectHclDirs, projectHclDirMap[projectHclFile]...)
 			workingDirs = append(workingDirs, projectHclDirMap[projectHclFile]...)
@@ -739,124 +989,19 @@ func main(cmd *cobra.Command, args []string) error {
 		config.Projects = oldConfig.Projects
 	}
 
-	lock := sync.Mutex{}
-	ctx := context.Background()
-	errGroup, _ := errgroup.WithContext(ctx)
-	sem := semaphore.NewWeighted(numExecutors)
-
-	for _, workingDir := range workingDirs {
-		terragruntFiles, err := getAllTerragruntFiles(workingDir)
-		if err != nil {
-			return err
-		}
-
-		if len(projectHclDirs) == 0 || createHclProjectChilds || (createHclProjectExternalChilds && workingDir == gitRoot) {
-			// Concurrently looking all dependencies
-			for _, terragruntPath := range terragruntFiles {
-				terragruntPath := terragruntPath // https://golang.org/doc/faq#closures_and_goroutines
-
-				// don't create atlantis projects already covered by project hcl file projects
-				skipProject := false
-				if createHclProjectExternalChilds && workingDir == gitRoot && len(projectHclDirs) > 0 {
-					for _, projectHclDir := range projectHclDirs {
-						if strings.HasPrefix(terragruntPath, projectHclDir) {
-							skipProject = true
-							break
-						}
-					}
-				}
-				if skipProject {
-					continue
-				}
-				if err := sem.Acquire(ctx, 1); err != nil {
-					return err
-				}
-
-				errGroup.Go(func() error {
-					defer sem.Release(1)
-					project, err := createProject(ctx, terragruntPath)
-					if err != nil {
-						return err
-					}
-					// if project and err are nil then skip this project
-					if err == nil && project == nil {
-						return nil
-					}
-
-					// Lock the list as only one goroutine should be writing to config.Projects at a time
-					lock.Lock()
-					defer lock.Unlock()
-
-					// When preserving existing projects, we should update existing blocks instead of creating a
-					// duplicate, when generating something which already has representation
-					if preserveProjects {
-						updateProject := false
-
-						// TODO: with Go 1.19, we can replace for loop with slices.IndexFunc for increased performance
-						for i := range config.Projects {
-							if config.Projects[i].Dir == project.Dir {
-								updateProject = true
-								log.Info("Updated project for ", terragruntPath)
-								config.Projects[i] = *project
-
-								// projects should be unique, let's exit for loop for performance
-								// once first occurrence is found and replaced
-								break
-							}
-						}
-
-						if !updateProject {
-							log.Info("Created project for ", terragruntPath)
-							config.Projects = append(config.Projects, *project)
-						}
-					} else {
-						log.Info("Created project for ", terragruntPath)
-						config.Projects = append(config.Projects, *project)
-					}
-
-					return nil
-				})
-			}
-
-			if err := errGroup.Wait(); err != nil {
-				return err
-			}
-		}
-		if len(projectHclDirs) > 0 && workingDir != gitRoot {
-			projectHcl := lookupProjectHcl(projectHclDirMap, workingDir)
-			err := sem.Acquire(ctx, 1)
-			if err != nil {
-				return err
-			}
-
-			errGroup.Go(func() error {
-				defer sem.Release(1)
-				project, err := createHclProject(ctx, terragruntFiles, workingDir, projectHcl)
-				if err != nil {
-					return err
-				}
-				// if project and err are nil then skip this project
-				if err == nil && project == nil {
-					return nil
-				}
-				// Lock the list as only one goroutine should be writing to config.Projects at a time
-				lock.Lock()
-				defer lock.Unlock()
-
-				log.Info("Created "+projectHcl+" project for ", workingDir)
-				config.Projects = append(config.Projects, *project)
-
-				return nil
-			})
-
-			if err := errGroup.Wait(); err != nil {
-				return err
-			}
-		}
+	collector := newProjectCollector(config.Projects)
+	if err := generateProjects(ctx, workingDirs, projectHclDirs, projectHclDirMap, collector); err != nil {
+		return err
 	}
+	config.Projects = collector.projects
 
-	// Sort the projects in config by Dir
-	sort.Slice(config.Projects, func(i, j int) bool { return config.Projects[i].Dir < config.Projects[j].Dir })
+	// Sort the projects in config by Dir, and by Name within a Dir, as they are generated in no particular order
+	sort.SliceStable(config.Projects, func(i, j int) bool {
+		if config.Projects[i].Dir == config.Projects[j].Dir {
+			return config.Projects[i].Name < config.Projects[j].Name
+		}
+		return config.Projects[i].Dir < config.Projects[j].Dir
+	})
 
 	if executionOrderGroups || dependsOn {
 		projectsMap := make(map[string]*AtlantisProject, len(config.Projects))
@@ -906,7 +1051,7 @@ func main(cmd *cobra.Command, args []string) error {
 
 		if hasChanges {
 			// Should be unreachable
-			log.Warn("Computing execution_order_groups failed. Probably cycle exists")
+			reportDiagnostic(ctx, ruleExecutionOrderCycle, "", nil, "Computing execution_order_groups failed. Probably cycle exists")
 		}
 
 		// Sort by execution_order_group
@@ -920,6 +1065,12 @@ func main(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if normalizeWhenModifiedPatterns {
+		for i := range config.Projects {
+			config.Projects[i].Autoplan.WhenModified = normalizeWhenModified(config.Projects[i].Autoplan.WhenModified)
+		}
+	}
+
 	// Convert config to YAML string
 	yamlBytes, err := yaml.Marshal(&config)
 	if err != nil {
@@ -940,6 +1091,11 @@ func main(cmd *cobra.Command, args []string) error {
 		log.Println(yamlString)
 	}
 
+	if failures := runOf(ctx).failures.list(); keepGoing && len(failures) > 0 {
+		printFailureSummary(os.Stderr, failures)
+		return &partialFailureError{failed: len(failures)}
+	}
+
 	return nil
 }
 
@@ -953,14 +1109,43 @@ var parallel bool
 var createWorkspace bool
 var createProjectName bool
 var defaultTerraformVersion string
+var detectTerraformVersions bool
+var defaultDistribution string
+var whenModifiedDefaults []string
+var whenModifiedExclude []string
+var normalizeWhenModifiedPatterns bool
 var defaultWorkflow string
 var filterPaths []string
 var outputPath string
 var preserveWorkflows bool
 var preserveProjects bool
 var cascadeDependencies bool
+var cascadeEdges []string
+var cascadeDepth int
+var mergeStrategies map[string]string
+var configFilenames []string
+var excludePatterns []string
+var useIgnoreFiles bool
+var followSymlinks bool
+var sidecarFilename string
+var rulesFile string
+var strict bool
+var keepGoing bool
+var parentPatterns []string
+var parentWhenIncluded bool
+var diagnosticsFormat string
+var diagnosticsOutput string
 var defaultApplyRequirements []string
 var numExecutors int64
+var generationTimeout time.Duration
+var unitTimeout time.Duration
+var sandbox bool
+var sandboxValues map[string]string
+var envValues []string
+var envFiles []string
+var cleanEnv bool
+var featureValues []string
+var featureMatrix bool
 var projectHclFiles []string
 var createHclProjectChilds bool
 var createHclProjectExternalChilds bool
@@ -980,7 +1165,7 @@ var generateCmd = &cobra.Command{
 			cmd.MarkFlagRequired("create-project-name")
 		}
 	},
-	RunE: main,
+	RunE: generateWithDiagnostics,
 }
 
 func init() {
@@ -993,8 +1178,10 @@ func init() {
 
 	generateCmd.PersistentFlags().BoolVar(&autoPlan, "autoplan", false, "Enable auto plan. Default is disabled")
 	generateCmd.PersistentFlags().BoolVar(&autoMerge, "automerge", false, "Enable auto merge. Default is disabled")
+	generateCmd.PersistentFlags().StringSliceVar(&parentPatterns, "parent-patterns", []string{}, "Globs of configs that are always parents, relative to the root, like root.hcl or _envcommon/**. Can be overridden by the atlantis_is_parent local. Default is no patterns")
+	generateCmd.PersistentFlags().BoolVar(&parentWhenIncluded, "parent-when-included", false, "Treats every config included by another config as a parent. Can be overridden by the atlantis_is_parent local. Default is disabled")
 	generateCmd.PersistentFlags().BoolVar(&ignoreParentTerragrunt, "ignore-parent-terragrunt", true, "Ignore parent terragrunt configs (those which don't reference a terraform module). Default is enabled")
-	generateCmd.PersistentFlags().BoolVar(&createParentProject, "create-parent-project", false, "Create a project for the parent terragrunt configs (those which don't reference a terraform module). Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&createParentProject, "create-parent-project", false, "Create a project for the parent terragrunt configs (those which don't reference a terraform module), with autoplan disabled unless overridden by rules or locals. Default is disabled")
 	generateCmd.PersistentFlags().BoolVar(&ignoreDependencyBlocks, "ignore-dependency-blocks", false, "When true, dependencies found in `dependency` blocks will be ignored")
 	generateCmd.PersistentFlags().BoolVar(&parallel, "parallel", true, "Enables plans and applys to happen in parallel. Default is enabled")
 	generateCmd.PersistentFlags().BoolVar(&createWorkspace, "create-workspace", false, "Use different workspace for each project. Default is use default workspace")
@@ -1002,13 +1189,40 @@ func init() {
 	generateCmd.PersistentFlags().BoolVar(&preserveWorkflows, "preserve-workflows", true, "Preserves workflows from old output files. Default is true")
 	generateCmd.PersistentFlags().BoolVar(&preserveProjects, "preserve-projects", false, "Preserves projects from old output files to enable incremental builds. Default is false")
 	generateCmd.PersistentFlags().BoolVar(&cascadeDependencies, "cascade-dependencies", true, "When true, dependencies will cascade, meaning that a module will be declared to depend not only on its dependencies, but all dependencies of its dependencies all the way down. Default is true")
+	generateCmd.PersistentFlags().StringSliceVar(&cascadeEdges, "cascade-edges", allDependencyKinds, "Comma separated list of the edge kinds dependencies cascade through: include, dependency, source, extra and var-file. Direct dependencies are always included. Default is all kinds")
+	generateCmd.PersistentFlags().IntVar(&cascadeDepth, "cascade-depth", 0, "The max number of levels dependencies cascade beyond a module's direct dependencies. Default is 0, meaning unlimited")
 	generateCmd.PersistentFlags().StringVar(&defaultWorkflow, "workflow", "", "Name of the workflow to be customized in the atlantis server. Default is to not set")
 	generateCmd.PersistentFlags().StringSliceVar(&defaultApplyRequirements, "apply-requirements", []string{}, "Requirements that must be satisfied before `atlantis apply` can be run. Currently the only supported requirements are `approved` and `mergeable`. Can be overridden by locals")
+	generateCmd.PersistentFlags().StringToStringVar(&mergeStrategies, "merge-strategy", map[string]string{}, "How locals are merged with the locals of parent configs, as local=strategy pairs, like atlantis_apply_requirements=append. Strategies are append, replace and deep. Can be overridden by locals")
+	generateCmd.PersistentFlags().StringVar(&sidecarFilename, "sidecar-filename", "", "Name of the sidecar files that can hold atlantis settings next to terragrunt configs or in any of their parent directories, like atlantis.hcl. Default is not to read sidecar files")
+	generateCmd.PersistentFlags().StringVar(&rulesFile, "rules-file", "", "Path of a YAML file with rules setting the workflow, apply requirements, autoplan and terraform version of projects by their path. Can be overridden by locals. Default is no rules")
+	generateCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Checks the types of all atlantis locals and fails on unknown atlantis_ locals before generating, like the lint command. Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&keepGoing, "keep-going", false, "Keeps generating the projects of healthy configs when some configs fail, printing a summary of the failures at the end and exiting with status 2. Default is to stop at the first failure")
+	generateCmd.PersistentFlags().StringVar(&diagnosticsFormat, "diagnostics-format", "", "Writes the warnings and errors of the run as json or sarif. Default is not to write diagnostics")
+	generateCmd.PersistentFlags().StringVar(&diagnosticsOutput, "diagnostics-output", "", "Path of the file where diagnostics are written. Default is to write to stdout")
 	generateCmd.PersistentFlags().StringVar(&outputPath, "output", "", "Path of the file where configuration will be generated. Default is not to write to file")
 	generateCmd.PersistentFlags().StringSliceVar(&filterPaths, "filter", []string{}, "Comma-separated paths or glob expressions to the directories you want scope down the config for. Default is all files in root.")
 	generateCmd.PersistentFlags().StringVar(&gitRoot, "root", pwd, "Path to the root directory of the git repo you want to build config for. Default is current dir")
 	generateCmd.PersistentFlags().StringVar(&defaultTerraformVersion, "terraform-version", "", "Default terraform version to specify for all modules. Can be overriden by locals")
-	generateCmd.PersistentFlags().Int64Var(&numExecutors, "num-executors", 15, "Number of executors used for parallel generation of projects. Default is 15")
+	generateCmd.PersistentFlags().StringVar(&defaultDistribution, "distribution", "", "Default terraform distribution for all modules, either terraform or opentofu. Can be overriden by locals. Default is to not set")
+	generateCmd.PersistentFlags().BoolVar(&detectTerraformVersions, "detect-terraform-version", false, "Detect the terraform version of each module from .terraform-version and .tool-versions files, or from the required_version of its local terraform source. Can be overriden by locals and rules")
+	generateCmd.PersistentFlags().StringSliceVar(&configFilenames, "config-filename", []string{}, "Name of the terragrunt configs to look for, each also matching its JSON or HCL variant. Can be given more than once, in order of precedence. Default is terragrunt.hcl")
+	generateCmd.PersistentFlags().StringSliceVar(&excludePatterns, "exclude", []string{}, "Globs of paths relative to the root that are skipped when looking for configs and scanning for when_modified dependencies. A directory that matches excludes everything in it. Default is no patterns")
+	generateCmd.PersistentFlags().BoolVar(&useIgnoreFiles, "use-ignore-files", false, "Also excludes the paths ignored by .gitignore and .atlantisignore files. Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follows symlinked directories below the root when looking for configs, and resolves symlinks in when_modified paths to the files they point to. Default is disabled")
+	generateCmd.PersistentFlags().StringSliceVar(&whenModifiedDefaults, "when-modified-defaults", []string{}, "Comma-separated patterns every project's when_modified list starts with. Default is *.hcl and the terraform files of the distribution")
+	generateCmd.PersistentFlags().StringSliceVar(&whenModifiedExclude, "when-modified-exclude", []string{}, "Comma-separated patterns to exclude from every project's when_modified list. Can be extended by locals")
+	generateCmd.PersistentFlags().BoolVar(&normalizeWhenModifiedPatterns, "normalize-when-modified", false, "Cleans paths, drops patterns covered by broader globs and sorts the when_modified lists, so the output stays small and stable. Default is false")
+	generateCmd.PersistentFlags().Int64Var(&numExecutors, "num-executors", 15, "Max number of configs parsed at once, across all working dirs. Default is 15")
+	generateCmd.PersistentFlags().DurationVar(&generationTimeout, "timeout", 0, "Stops generation once this long has passed, like 5m, naming the configs still being processed. Default is no timeout")
+	generateCmd.PersistentFlags().DurationVar(&unitTimeout, "unit-timeout", 0, "Fails the project of a config that takes longer than this to evaluate, like 30s. Default is no timeout")
+	generateCmd.PersistentFlags().BoolVar(&sandbox, "sandbox", false, "Replaces terragrunt functions with side effects, like run_cmd, sops_decrypt_file and the get_aws_* functions, with stubs that log each distinct call of a config once. Default is disabled")
+	generateCmd.PersistentFlags().StringToStringVar(&sandboxValues, "sandbox-value", map[string]string{}, "Fixed values returned by sandboxed functions, as function=value pairs, like get_aws_account_id=123456789012. Default is a placeholder for each function")
+	generateCmd.PersistentFlags().StringArrayVar(&envValues, "env", []string{}, "Env var seen by get_env, as KEY=VALUE. Can be given more than once, and overrides --env-file and the process environment")
+	generateCmd.PersistentFlags().StringSliceVar(&envFiles, "env-file", []string{}, "Files of KEY=VALUE lines with env vars seen by get_env. Can be given more than once, later files overriding earlier ones and the process environment")
+	generateCmd.PersistentFlags().BoolVar(&cleanEnv, "clean-env", false, "Hides the environment of the process from get_env, so it only sees --env and --env-file values. Default is to see the whole environment")
+	generateCmd.PersistentFlags().StringArrayVar(&featureValues, "feature", []string{}, "Value of a terragrunt feature flag, as name=value, overriding its default. Can be given more than once")
+	generateCmd.PersistentFlags().BoolVar(&featureMatrix, "feature-matrix", false, "Adds the dependencies of every combination of feature flag values to each project: the default, both values of bool flags and every --feature value. Default is to only use the default or --feature value")
 	generateCmd.PersistentFlags().StringSliceVar(&projectHclFiles, "project-hcl-files", []string{}, "Comma-separated names of arbitrary hcl files in the terragrunt hierarchy to create Atlantis projects for. Disables the --filter flag")
 	generateCmd.PersistentFlags().BoolVar(&createHclProjectChilds, "create-hcl-project-childs", false, "Creates Atlantis projects for terragrunt child modules below the directories containing the HCL files defined in --project-hcl-files")
 	generateCmd.PersistentFlags().BoolVar(&createHclProjectExternalChilds, "create-hcl-project-external-childs", true, "Creates Atlantis projects for terragrunt child modules outside the directories containing the HCL files defined in --project-hcl-files")
@@ -1,16 +1,26 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/hashicorp/hcl/v2"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
-	"golang.org/x/sync/singleflight"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // Resets all flag values to their defaults in between tests
@@ -20,17 +30,44 @@ func resetForRun() error {
 		return err
 	}
 
-	// reset caches
-	getDependenciesCache = newGetDependenciesCache()
-	requestGroup = singleflight.Group{}
+	// Units given up on by the previous run still read the flags, so wait for them to stop
+	latestRun.evaluations.Wait()
+
 	// reset flags
 	gitRoot = pwd
 	autoPlan = false
 	autoMerge = false
 	cascadeDependencies = true
+	cascadeEdges = allDependencyKinds
+	cascadeDepth = 0
+	mergeStrategies = map[string]string{}
+	configFilenames = []string{}
+	excludePatterns = []string{}
+	useIgnoreFiles = false
+	followSymlinks = false
+	sidecarFilename = ""
+	rulesFile = ""
+	strict = false
+	keepGoing = false
+	diagnosticsFormat = ""
+	diagnosticsOutput = ""
+	parentPatterns = []string{}
+	parentWhenIncluded = false
+	createParentProject = false
 	ignoreParentTerragrunt = true
 	ignoreDependencyBlocks = false
 	parallel = true
+	numExecutors = 15
+	generationTimeout = 0
+	unitTimeout = 0
+	sandbox = false
+	lintSandbox = true
+	sandboxValues = map[string]string{}
+	envValues = []string{}
+	envFiles = []string{}
+	cleanEnv = false
+	featureValues = []string{}
+	featureMatrix = false
 	createWorkspace = false
 	createProjectName = false
 	preserveWorkflows = true
@@ -39,6 +76,11 @@ func resetForRun() error {
 	filterPaths = []string{}
 	outputPath = ""
 	defaultTerraformVersion = ""
+	detectTerraformVersions = false
+	defaultDistribution = ""
+	whenModifiedDefaults = []string{}
+	whenModifiedExclude = []string{}
+	normalizeWhenModifiedPatterns = false
 	defaultApplyRequirements = []string{}
 	projectHclFiles = []string{}
 	createHclProjectChilds = false
@@ -144,6 +186,31 @@ func TestNotIgnoringParentTerragrunt(t *testing.T) {
 	})
 }
 
+// The atlantis_is_parent local overrides the heuristic in both directions
+func TestParentDetectionFromLocals(t *testing.T) {
+	runTest(t, filepath.Join("golden", "parent_detection.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "parent_detection"),
+	})
+}
+
+func TestParentDetectionWhenIncluded(t *testing.T) {
+	runTest(t, filepath.Join("golden", "parent_detection_explicit.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "parent_detection"),
+		"--parent-when-included",
+	})
+}
+
+func TestParentDetectionFromPatterns(t *testing.T) {
+	runTest(t, filepath.Join("golden", "parent_detection_explicit.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "parent_detection"),
+		"--parent-patterns",
+		"terragrunt.hcl",
+	})
+}
+
 func TestEnablingAutoplan(t *testing.T) {
 	runTest(t, filepath.Join("golden", "withAutoplan.yaml"), []string{
 		"--root",
@@ -181,7 +248,7 @@ func TestNonStringErrorOnExtraDeclaredDependencies(t *testing.T) {
 		filepath.Join("..", "test_examples_errors", "extra_dependency_error"),
 	})
 	err = rootCmd.Execute()
-	
+
 	expectedError := "extra_atlantis_dependencies contains non-string value at position 4"
 	if err == nil || err.Error() != expectedError {
 		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
@@ -334,275 +401,1245 @@ func TestTerraformVersionConfig(t *testing.T) {
 	})
 }
 
-func TestPreservingOldWorkflows(t *testing.T) {
-	err := resetForRun()
-	if err != nil {
-		t.Error("Failed to reset default flags")
-		return
-	}
-
-	randomInt := rand.Int()
-	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
-	defer os.Remove(filename)
-
-	// Create an existing file to simulate an existing atlantis.yaml file
-	contents := []byte(`workflows:
-  terragrunt:
-    apply:
-      steps:
-      - run: terragrunt apply -no-color $PLANFILE
-    plan:
-      steps:
-      - run: terragrunt plan -no-color -out $PLANFILE
-`)
-	os.WriteFile(filename, contents, 0644)
-
-	content, err := RunWithFlags(filename, []string{
-		"generate",
-		"--output",
-		filename,
+func TestDetectingTerraformVersion(t *testing.T) {
+	runTest(t, filepath.Join("golden", "terraform_version_detection.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "basic_module"),
+		filepath.Join("..", "test_examples", "terraform_version_detection"),
+		"--detect-terraform-version",
+		"--terraform-version", "0.14.9001",
 	})
-	if err != nil {
-		t.Error("Failed to read file")
-		return
-	}
-
-	goldenContents, err := os.ReadFile(filepath.Join("golden", "oldWorkflowsPreserved.yaml"))
-	if err != nil {
-		t.Error("Failed to read golden file")
-		return
-	}
-
-	if string(content) != string(goldenContents) {
-		t.Errorf("Content did not match golden file.\n\nExpected Content: %s\n\nContent: %s", string(goldenContents), string(content))
-	}
 }
 
-func TestPreservingOldProjects(t *testing.T) {
-	err := resetForRun()
-	if err != nil {
-		t.Error("Failed to reset default flags")
-		return
-	}
-
-	randomInt := rand.Int()
-	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
-	defer os.Remove(filename)
-
-	// Create an existing file to simulate an existing atlantis.yaml file
-	contents := []byte(`projects:
-- autoplan:
-    enabled: false
-    when_modified:
-    - '*.hcl'
-    - '*.tf*'
-  dir: someDir
-  name: projectFromPreviousRun
-`)
-	os.WriteFile(filename, contents, 0644)
-
-	content, err := RunWithFlags(filename, []string{
-		"generate",
-		"--preserve-projects",
-		"--output",
-		filename,
+// A version set by a rule is used instead of the detected one, while a local still takes precedence over the rule
+func TestDetectingTerraformVersionWithRules(t *testing.T) {
+	runTest(t, filepath.Join("golden", "terraform_version_detection_rules.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "basic_module"),
+		filepath.Join("..", "test_examples", "terraform_version_detection"),
+		"--detect-terraform-version",
+		"--terraform-version", "0.14.9001",
+		"--rules-file",
+		filepath.Join("..", "test_examples", "terraform_version_detection", "rules.yaml"),
 	})
-	if err != nil {
-		t.Error("Failed to read file")
-		return
-	}
+}
 
-	goldenContents, err := os.ReadFile(filepath.Join("golden", "oldProjectsPreserved.yaml"))
-	if err != nil {
-		t.Error("Failed to read golden file")
-		return
+// Constraints are conflicting when no version satisfies all of them, whether or not one of them is an exact pin
+func TestConflictingRequiredVersions(t *testing.T) {
+	cases := []struct {
+		constraints []string
+		expected    string
+		conflicting bool
+	}{
+		{[]string{"1.5.7", ">= 1.5"}, "1.5.7", false},
+		{[]string{"1.5.7", ">= 1.6"}, "1.5.7", true},
+		{[]string{">= 1.6", "< 1.5"}, "", true},
+		{[]string{">= 1.5", "< 1.5"}, "", true},
+		{[]string{">= 1.5", "<= 1.5"}, "", false},
+		{[]string{">= 1.5, < 2.0", "~> 1.7.0"}, "", false},
+		{[]string{"~> 1.5.0", ">= 1.6"}, "", true},
+		{[]string{"~> 1.5", ">= 1.9"}, "", false},
+		{[]string{"> 1.0", "!= 1.5.0"}, "", false},
 	}
 
-	if string(content) != string(goldenContents) {
-		t.Errorf("Content did not match golden file.\n\nExpected Content: %s\n\nContent: %s", string(goldenContents), string(content))
+	for _, c := range cases {
+		ctx := startRun(context.Background())
+		constraints := []requiredCoreConstraint{}
+		for i, constraint := range c.constraints {
+			constraints = append(constraints, requiredCoreConstraint{dir: fmt.Sprintf("module%d", i), constraint: constraint})
+		}
+
+		assert.Equal(t, c.expected, resolveRequiredCore(ctx, constraints), c.constraints)
+		assert.Equal(t, c.conflicting, len(runOf(ctx).diagnostics.list()) > 0, c.constraints)
 	}
 }
 
-func TestEnablingAutomerge(t *testing.T) {
-	runTest(t, filepath.Join("golden", "withAutomerge.yaml"), []string{
+func TestOpenTofuDistribution(t *testing.T) {
+	runTest(t, filepath.Join("golden", "opentofu.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "basic_module"),
-		"--automerge",
+		filepath.Join("..", "test_examples", "opentofu"),
+		"--distribution", "opentofu",
+		"--detect-terraform-version",
 	})
 }
 
-func TestChainedDependencies(t *testing.T) {
-	runTest(t, filepath.Join("golden", "chained_dependency.yaml"), []string{
+func TestOpenTofuDistributionFromRules(t *testing.T) {
+	runTest(t, filepath.Join("golden", "opentofu.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "chained_dependencies"),
-		"--cascade-dependencies",
+		filepath.Join("..", "test_examples", "opentofu"),
+		"--rules-file",
+		filepath.Join("..", "test_examples", "opentofu", "rules.yaml"),
+		"--detect-terraform-version",
 	})
 }
 
-func TestChainedDependenciesHiddenBehindFlag(t *testing.T) {
-	runTest(t, filepath.Join("golden", "chained_dependency_no_flag.yaml"), []string{
+func TestWhenModifiedDefaultsAndExclusions(t *testing.T) {
+	runTest(t, filepath.Join("golden", "when_modified.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "chained_dependencies"),
-		"--cascade-dependencies=false",
+		filepath.Join("..", "test_examples", "when_modified"),
+		"--when-modified-defaults", "*.hcl,*.tf,*.tfvars",
+		"--when-modified-exclude", "*.md",
 	})
 }
 
-func TestApplyRequirementsLocals(t *testing.T) {
-	runTest(t, filepath.Join("golden", "apply_overrides.yaml"), []string{
+func TestNormalizingWhenModified(t *testing.T) {
+	runTest(t, filepath.Join("golden", "normalize_when_modified.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "apply_requirements_overrides"),
+		filepath.Join("..", "test_examples", "normalize_when_modified"),
+		"--normalize-when-modified",
 	})
 }
 
-func TestApplyRequirementsFlag(t *testing.T) {
-	runTest(t, filepath.Join("golden", "apply_overrides_flag.yaml"), []string{
-		"--root",
-		filepath.Join("..", "test_examples", "basic_module"),
-		"--apply-requirements=approved,mergeable",
-	})
+// Patterns outside of the project are never covered by a wildcard, which doesn't match `..`
+func TestNormalizingWhenModifiedKeepsParentDirs(t *testing.T) {
+	assert.Equal(t, []string{"*/*.tf*", "../*.tf*", "../modules/*.tf*", "?*/modules/*.tf*"}, normalizeWhenModified([]string{
+		"*/*.tf*",
+		"../*.tf*",
+		"*.*/*.tf*",
+		"?/modules/*.tf*",
+		"../modules/*.tf*",
+		"?*/modules/*.tf*",
+	}))
 }
 
-func TestFilterFlagWithInfraLiveProd(t *testing.T) {
-	runTest(t, filepath.Join("golden", "filterInfraLiveProd.yaml"), []string{
+func TestCascadingEdgeKindsAndDepth(t *testing.T) {
+	runTest(t, filepath.Join("golden", "cascade_edges.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
-		"--filter",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod"),
+		filepath.Join("..", "test_examples", "cascade_edges"),
+		"--cascade-edges",
+		"source",
+		"--cascade-depth",
+		"2",
 	})
 }
 
-func TestFilterFlagWithInfraLiveNonProd(t *testing.T) {
-	runTest(t, filepath.Join("golden", "filterInfraLiveNonProd.yaml"), []string{
+func TestCascadingThroughSharedDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "cascade_diamond.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
-		"--filter",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "non-prod"),
+		filepath.Join("..", "test_examples", "cascade_diamond"),
 	})
 }
 
-func TestFilterFlagWithInfraLiveProdAndNonProd(t *testing.T) {
-	runTest(t, filepath.Join("golden", "filterInfraLiveProdAndNonProd.yaml"), []string{
-		"--root",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
-		"--filter",
-		strings.Join(
-			[]string{
-				filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "non-prod"),
-				filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod"),
-			},
-			",",
-		),
-	})
-}
+// Configs reached through more than one path are only walked once per module
+func TestCascadeWalkReusesResults(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
 
-func TestFilterGlobFlagWithInfraLiveMySql(t *testing.T) {
-	runTest(t, filepath.Join("golden", "filterGlobInfraLiveMySQL.yaml"), []string{
-		"--root",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
-		"--filter",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "*", "*", "*", "mysql"),
-	})
-}
+	root, err := filepath.Abs(filepath.Join("..", "test_examples", "cascade_diamond"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	path := filepath.Join(root, "top", "terragrunt.hcl")
+	opts, err := options.NewTerragruntOptionsWithConfigPath(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	opts.OriginalTerragruntConfigPath = path
+	runCtx := startRun(context.Background())
+	opts.Env = getEnvs(runCtx)
+	ctx := newParsingContext(runCtx, opts)
 
-func TestMultipleIncludes(t *testing.T) {
-	runTest(t, filepath.Join("golden", "multiple_includes.yaml"), []string{
-		"--root",
-		filepath.Join("..", "test_examples", "multiple_includes"),
-		"--terraform-version", "0.14.9001",
-	})
+	direct, err := getDirectDependencies(ctx, path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	walker := newCascadeWalker(ctx, path, resolveCascadeSettings(direct.cascade))
+	for _, dep := range direct.dependencies {
+		walker.walk(dep, 1)
+	}
+
+	bottom := filepath.ToSlash(filepath.Join(root, "bottom", "terragrunt.hcl"))
+	base := filepath.ToSlash(filepath.Join(root, "base", "terragrunt.hcl"))
+	assert.Equal(t, []string{base}, walker.results[bottom])
 }
 
-func TestRemoteModuleSourceBitbucket(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestNegativeCascadeDepth(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_bitbucket"),
+		filepath.Join("..", "test_examples", "cascade_diamond"),
+		"--cascade-depth",
+		"-1",
 	})
+	err = rootCmd.Execute()
+
+	expectedError := "invalid cascade depth -1, must be 0 for unlimited or a positive number of levels"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+
+	_, err = resolveCascadeLocals(cty.ObjectVal(map[string]cty.Value{"depth": cty.NumberIntVal(-1)}))
+	expectedError = "atlantis_cascade: " + expectedError
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
 }
 
-func TestRemoteModuleSourceGCS(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestLocalsFromIncludeChain(t *testing.T) {
+	runTest(t, filepath.Join("golden", "include_chain.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_gcs"),
+		filepath.Join("..", "test_examples", "include_chain"),
 	})
 }
 
-func TestRemoteModuleSourceGitHTTPS(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestCreatingParentProjects(t *testing.T) {
+	runTest(t, filepath.Join("golden", "create_parent_project.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_git_https"),
+		filepath.Join("..", "test_examples", "include_chain"),
+		"--create-parent-project",
 	})
 }
 
-func TestRemoteModuleSourceGitSCPLike(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+// Dependencies on units written in JSON point at their terragrunt.hcl.json file
+func TestConfigFilenameJSONDependency(t *testing.T) {
+	runTest(t, filepath.Join("golden", "config_filename.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_git_scp_like"),
+		filepath.Join("..", "test_examples", "config_filename"),
 	})
 }
 
-func TestRemoteModuleSourceGitSSH(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestCustomConfigFilenames(t *testing.T) {
+	runTest(t, filepath.Join("golden", "config_filename_custom.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_git_ssh"),
+		filepath.Join("..", "test_examples", "config_filename"),
+		"--config-filename",
+		"unit.hcl",
+		"--config-filename",
+		"terragrunt.hcl",
 	})
 }
 
-func TestRemoteModuleSourceGithubHTTPS(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestMergeStrategies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "merge_strategy.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_github_https"),
+		filepath.Join("..", "test_examples", "merge_strategy"),
+		"--merge-strategy",
+		"extra_atlantis_dependencies=deep",
 	})
 }
 
-func TestRemoteModuleSourceGithubSSH(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestNestedAtlantisLocal(t *testing.T) {
+	runTest(t, filepath.Join("golden", "nested_atlantis_local.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_github_ssh"),
+		filepath.Join("..", "test_examples", "nested_atlantis_local"),
 	})
 }
 
-func TestRemoteModuleSourceHTTP(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestSidecarFiles(t *testing.T) {
+	runTest(t, filepath.Join("golden", "sidecar.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_http"),
+		filepath.Join("..", "test_examples", "sidecar"),
+		"--sidecar-filename",
+		"atlantis.hcl",
 	})
 }
 
-func TestRemoteModuleSourceHTTPS(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestSidecarFilesDisabled(t *testing.T) {
+	runTest(t, filepath.Join("golden", "sidecar_disabled.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_https"),
+		filepath.Join("..", "test_examples", "sidecar"),
 	})
 }
 
-func TestRemoteModuleSourceMercurial(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestProjectRules(t *testing.T) {
+	runTest(t, filepath.Join("golden", "rules.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_mercurial"),
+		filepath.Join("..", "test_examples", "rules"),
+		"--autoplan",
+		"--rules-file",
+		filepath.Join("..", "test_examples", "rules", "rules.yaml"),
 	})
 }
 
-func TestRemoteModuleSourceS3(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestProjectRulesWithoutMatch(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rulesPath := filepath.Join("..", "test_examples_errors", "rules_error", "rules.yaml")
+	rootCmd.SetArgs([]string{
+		"generate",
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_s3"),
+		filepath.Join("..", "test_examples", "rules"),
+		"--rules-file",
+		rulesPath,
 	})
+	err = rootCmd.Execute()
+
+	expectedError := rulesPath + ": rule 1 has no match pattern"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
 }
 
-func TestRemoteModuleSourceTerraformRegistry(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestLint(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	output := &bytes.Buffer{}
+	rootCmd.SetOut(output)
+	defer rootCmd.SetOut(nil)
+	rootCmd.SetArgs([]string{
+		"lint",
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_terraform_registry"),
+		filepath.Join("..", "test_examples_errors", "lint"),
+		"--sidecar-filename",
+		"atlantis.hcl",
 	})
-}
+	err = rootCmd.Execute()
 
-func TestEnvHCLProjectsNoChilds(t *testing.T) {
-	runTest(t, filepath.Join("golden", "envhcl_nochilds.yaml"), []string{
-		"--root",
+	expectedError := "found 12 problem(s) in atlantis locals"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+
+	assert.Equal(t, []string{
+		"app/terragrunt.hcl:6:33: atlantis_autoplan must be a bool, got string",
+		"app/terragrunt.hcl:7:33: atlantis_workflow must be a string, got number",
+		"app/terragrunt.hcl:8:33: atlantis_apply_requirements must be a list of string, got bool at position 1",
+		"app/terragrunt.hcl:10:3: unknown local atlantis_custom_setting",
+		"cascade/terragrunt.hcl:2:29: atlantis_cascade enabled must be a bool, got string",
+		"cascade/terragrunt.hcl:3:29: atlantis_merge_strategy value for when_modified must be a string, got number",
+		"json/terragrunt.hcl.json:3:35: atlantis_terraform_version must be a string, got number",
+		"nested/atlantis.hcl:1:12: workflow must be a string, got tuple",
+		"nested/terragrunt.hcl:4:30: atlantis.autoplan must be a bool, got string",
+		`nested/terragrunt.hcl:5:30: atlantis.terraform_distribution is invalid: unsupported terraform distribution "unknown", must be one of "terraform" or "opentofu"`,
+		"nested/terragrunt.hcl:6:5: unknown attribute atlantis.aply_requirements, did you mean apply_requirements?",
+		"root.hcl:2:3: unknown local atlantis_worklfow, did you mean atlantis_workflow?",
+	}, strings.Split(strings.TrimSpace(output.String()), "\n"))
+}
+
+func TestWrongLocalType(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples_errors", "wrong_local_type"),
+	})
+	err = rootCmd.Execute()
+
+	expectedError := "app/terragrunt.hcl: atlantis_workflow must be a string, got number"
+	if err == nil || !strings.HasSuffix(filepath.ToSlash(err.Error()), expectedError) {
+		t.Errorf("Expected error ending with '%s', got '%v'", expectedError, err)
+	}
+}
+
+// Values of the nested `atlantis` local are not converted to the type of their local, so `"false"` isn't read as false
+func TestWrongNestedLocalType(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples_errors", "wrong_nested_local_type"),
+	})
+	err = rootCmd.Execute()
+
+	expectedError := "app/terragrunt.hcl: atlantis.autoplan must be a bool, got string"
+	if err == nil || !strings.HasSuffix(filepath.ToSlash(err.Error()), expectedError) {
+		t.Errorf("Expected error ending with '%s', got '%v'", expectedError, err)
+	}
+}
+
+func TestWrongSidecarType(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples_errors", "wrong_sidecar_type"),
+		"--sidecar-filename",
+		"atlantis.hcl",
+	})
+	err = rootCmd.Execute()
+
+	expectedError := "wrong_sidecar_type/atlantis.hcl: workflow must be a string, got number"
+	if err == nil || !strings.HasSuffix(filepath.ToSlash(err.Error()), expectedError) {
+		t.Errorf("Expected error ending with '%s', got '%v'", expectedError, err)
+	}
+}
+
+// Conflicts between nested and prefixed locals are reported and logged once per run, in every run
+func TestConflictingLocalsAreReportedEveryRun(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	output := &bytes.Buffer{}
+	log.SetOutput(output)
+	defer log.SetOutput(os.Stderr)
+
+	rawLocals := map[string]cty.Value{
+		"atlantis":          cty.ObjectVal(map[string]cty.Value{"workflow": cty.StringVal("nested")}),
+		"atlantis_workflow": cty.StringVal("prefixed"),
+	}
+	for i := 0; i < 2; i++ {
+		ctx := startRun(context.Background())
+		for j := 0; j < 2; j++ {
+			expanded, err := expandAtlantisLocal(ctx, rawLocals, "app/terragrunt.hcl")
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, cty.StringVal("prefixed"), expanded["atlantis_workflow"])
+		}
+		assert.Equal(t, 1, len(runOf(ctx).diagnostics.list()))
+	}
+	assert.Equal(t, 2, strings.Count(output.String(), "both atlantis.workflow and atlantis_workflow are set"))
+}
+
+func TestStrictMode(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--strict",
+	})
+}
+
+func TestStrictModeWithInvalidLocals(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples_errors", "lint"),
+		"--strict",
+		"--sidecar-filename",
+		"atlantis.hcl",
+	})
+	err = rootCmd.Execute()
+
+	expectedError := "strict mode found 12 problem(s) in atlantis locals"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
+
+func TestKeepGoing(t *testing.T) {
+	runTest(t, filepath.Join("golden", "keep_going.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples_errors", "keep_going"),
+		"--keep-going",
+	})
+}
+
+func TestKeepGoingReportsFailures(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples_errors", "keep_going"),
+		"--keep-going",
+	})
+	err = rootCmd.Execute()
+
+	var partialFailure *partialFailureError
+	if !errors.As(err, &partialFailure) {
+		t.Errorf("Expected a partial failure, got '%v'", err)
+		return
+	}
+	assert.Equal(t, 2, partialFailure.failed)
+
+	failures := latestRun.failures.list()
+	assert.Equal(t, 2, len(failures))
+	assert.True(t, strings.HasSuffix(filepath.ToSlash(failures[0].configPath), "keep_going/broken/terragrunt.hcl"))
+	if assert.NotNil(t, failures[0].rng) {
+		assert.Equal(t, hcl.Pos{Line: 6, Column: 12}, hcl.Pos{Line: failures[0].rng.Start.Line, Column: failures[0].rng.Start.Column})
+		assert.Equal(t, hcl.Pos{Line: 6, Column: 25}, hcl.Pos{Line: failures[0].rng.End.Line, Column: failures[0].rng.End.Column})
+	}
+	assert.True(t, strings.HasSuffix(filepath.ToSlash(failures[1].configPath), "keep_going/shared/config.hcl"))
+}
+
+func TestKeepGoingWithWrongLocalType(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples_errors", "wrong_local_type"),
+		"--keep-going",
+	})
+	err = rootCmd.Execute()
+
+	var partialFailure *partialFailureError
+	if !errors.As(err, &partialFailure) {
+		t.Errorf("Expected a partial failure, got '%v'", err)
+		return
+	}
+	failures := latestRun.failures.list()
+	if assert.Equal(t, 1, len(failures)) {
+		assert.True(t, strings.HasSuffix(filepath.ToSlash(failures[0].configPath), "wrong_local_type/app/terragrunt.hcl"))
+	}
+}
+
+func TestUnitPanicIsAnError(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	project, _, err := runUnit(context.Background(), "app/terragrunt.hcl", func(ctx context.Context) (*AtlantisProject, error) {
+		panic("not a string")
+	})
+
+	assert.Nil(t, project)
+	var panicErr *unitPanicError
+	if assert.True(t, errors.As(err, &panicErr)) {
+		assert.Equal(t, "app/terragrunt.hcl: panicked while being evaluated: not a string", err.Error())
+	}
+}
+
+func TestUnitTimeout(t *testing.T) {
+	runTest(t, filepath.Join("golden", "slow_unit.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples_errors", "slow_unit"),
+		"--unit-timeout=500ms",
+		"--keep-going",
+	})
+
+	failures := latestRun.failures.list()
+	if assert.Equal(t, 1, len(failures)) {
+		var timeoutErr *unitTimeoutError
+		assert.True(t, errors.As(failures[0].err, &timeoutErr))
+		assert.True(t, strings.HasSuffix(filepath.ToSlash(failures[0].configPath), "slow_unit/slow/terragrunt.hcl"))
+	}
+}
+
+// A unit given up on keeps being evaluated, and signals when it has returned
+func TestUnitGivenUpOnSignalsWhenFinished(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	unitTimeout = 10 * time.Millisecond
+
+	release := make(chan struct{})
+	_, finished, err := runUnit(context.Background(), "app/terragrunt.hcl", func(ctx context.Context) (*AtlantisProject, error) {
+		<-release
+		return nil, nil
+	})
+
+	var timeoutErr *unitTimeoutError
+	assert.True(t, errors.As(err, &timeoutErr))
+	select {
+	case <-finished:
+		t.Error("Expected the unit to still be running")
+	default:
+	}
+
+	close(release)
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the unit to finish once released")
+	}
+}
+
+// Units given up on keep their executor until they return, so with a single executor the two units that each
+// take a second are evaluated one after the other, even though each is given up on much sooner
+func TestUnitsGivenUpOnKeepTheirExecutor(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	unitTimeout = 10 * time.Millisecond
+	numExecutors = 1
+	keepGoing = true
+
+	var running, maxRunning int32
+	// Ignores its context, like a config stuck in a call that can't be interrupted
+	slowUnit := func(ctx context.Context) (*AtlantisProject, error) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			seen := atomic.LoadInt32(&maxRunning)
+			if current <= seen || atomic.CompareAndSwapInt32(&maxRunning, seen, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil, nil
+	}
+
+	ctx := startRun(context.Background())
+	scheduler := newUnitScheduler(ctx)
+	for _, path := range []string{"first", "second", "third"} {
+		scheduler.schedule(path, slowUnit, func(project AtlantisProject) {})
+	}
+	assert.Nil(t, scheduler.wait())
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxRunning))
+	assert.Equal(t, 3, len(runOf(ctx).failures.list()))
+}
+
+func TestTimeoutNamesUnitsInProgress(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples_errors", "slow_unit"),
+		"--timeout=500ms",
+	})
+	err = rootCmd.Execute()
+
+	expectedError := "generation timed out after 500ms while still processing slow/terragrunt.hcl"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
+
+// A unit that is cut short must not cache the errors it gets from then on, as other units would get them too
+func TestCancelledEvaluationIsNotCached(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	path, err := filepath.Abs(filepath.Join("..", "test_examples_errors", "slow_unit", "slow", "terragrunt.hcl"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	opts, err := options.NewTerragruntOptionsWithConfigPath(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	opts.OriginalTerragruntConfigPath = path
+
+	ctx, cancel := context.WithCancel(startRun(context.Background()))
+	opts.Env = getEnvs(ctx)
+	cancel()
+	_, err = getDirectDependencies(newParsingContext(ctx, opts), path)
+	assert.Error(t, err)
+
+	_, cached := runOf(ctx).dependencies.get(path)
+	assert.False(t, cached)
+}
+
+// Each run has its own caches and reports, so units of an earlier run can't write into them
+func TestRunsDontShareState(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	first := startRun(context.Background())
+	second := startRun(context.Background())
+
+	reportDiagnostic(first, ruleModuleSkipped, "app/terragrunt.hcl", nil, "skipped by atlantis_skip")
+	assert.Equal(t, 1, len(runOf(first).diagnostics.list()))
+	assert.Equal(t, 0, len(runOf(second).diagnostics.list()))
+	assert.Same(t, runOf(second), latestRun)
+}
+
+// The state a run reads while evaluating configs is loaded into the run, so units of an earlier run keep reading
+// their own state after a later run starts
+func TestRunsDontShareLoadedState(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	envValues = []string{"STAGE=first"}
+	featureValues = []string{"use_legacy_vpc=true"}
+	first := startRun(context.Background())
+	if err := loadRunState(first, true); err != nil {
+		t.Fatal(err)
+	}
+
+	envValues = []string{"STAGE=second"}
+	featureValues = []string{}
+	second := startRun(context.Background())
+	if err := loadRunState(second, false); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "first", getEnvs(first)["STAGE"])
+	assert.Equal(t, "second", getEnvs(second)["STAGE"])
+	assert.True(t, runOf(first).sandbox)
+	assert.False(t, runOf(second).sandbox)
+
+	opts := options.NewTerragruntOptions()
+	applyFeatureFlags(first, opts, nil)
+	value, ok := opts.FeatureFlags.Load("use_legacy_vpc")
+	assert.True(t, ok)
+	assert.Equal(t, "true", value)
+	opts = options.NewTerragruntOptions()
+	applyFeatureFlags(second, opts, nil)
+	assert.Equal(t, 0, opts.FeatureFlags.Size())
+}
+
+// Creates the tree of configs used by the parse cache tests: a root.hcl whose locals depend on the config
+// including it, included by a config in each of `envs`
+func writeSharedIncludeTree(t testing.TB, envs []string) (string, []string) {
+	root := t.TempDir()
+	files := map[string]string{
+		"root.hcl": `locals {
+  atlantis_workflow           = path_relative_to_include()
+  atlantis_apply_requirements = [find_in_parent_folders("env.hcl")]
+}
+`,
+	}
+	children := []string{}
+	for _, env := range envs {
+		files[filepath.Join(env, "env.hcl")] = ""
+		files[filepath.Join(env, "app", "terragrunt.hcl")] = `include "root" {
+  path = find_in_parent_folders("root.hcl")
+}
+`
+		children = append(children, filepath.Join(root, env, "app", "terragrunt.hcl"))
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, children
+}
+
+// Returns a parsing context of `ctx` that evaluates files for the config at `path`
+func parsingContextFor(t testing.TB, ctx context.Context, path string) *config.ParsingContext {
+	opts, err := options.NewTerragruntOptionsWithConfigPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.OriginalTerragruntConfigPath = path
+	opts.Env = getEnvs(ctx)
+	return newParsingContext(ctx, opts)
+}
+
+// A file included by many configs is parsed once per run, while its locals are evaluated for each config
+// including it, as functions like `path_relative_to_include` return something else for each of them
+func TestSharedIncludeIsParsedOnce(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	root, children := writeSharedIncludeTree(t, []string{"dev", "prod"})
+	gitRoot = root
+	ctx := startRun(context.Background())
+	devCtx := parsingContextFor(t, ctx, children[0])
+	prodCtx := parsingContextFor(t, ctx, children[1])
+
+	devLocals, err := parseLocals(devCtx, children[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prodLocals, err := parseLocals(prodCtx, children[1], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "dev/app", devLocals.AtlantisWorkflow)
+	assert.Equal(t, []string{filepath.Join(root, "dev", "env.hcl")}, devLocals.ApplyRequirements)
+	assert.Equal(t, "prod/app", prodLocals.AtlantisWorkflow)
+	assert.Equal(t, []string{filepath.Join(root, "prod", "env.hcl")}, prodLocals.ApplyRequirements)
+
+	// Both children and the root.hcl they share
+	cache := runOf(ctx).parseCache
+	assert.Equal(t, 3, len(cache.files.data))
+	devFile, err := readConfigFile(devCtx, filepath.Join(root, "root.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prodFile, err := readConfigFile(prodCtx, filepath.Join(root, "root.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Same(t, devFile.File, prodFile.File)
+	assert.Equal(t, 2, len(cache.locals.data))
+
+	// Evaluating the locals for a config again reuses them
+	devLocals, err = parseLocals(devCtx, children[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "dev/app", devLocals.AtlantisWorkflow)
+	assert.Equal(t, 2, len(cache.locals.data))
+}
+
+// Resolves the locals of many configs sharing a root.hcl, as a run does
+func BenchmarkParseLocalsWithSharedInclude(b *testing.B) {
+	if err := resetForRun(); err != nil {
+		b.Fatal("Failed to reset default flags")
+	}
+
+	envs := []string{}
+	for i := 0; i < 50; i++ {
+		envs = append(envs, fmt.Sprintf("env%d", i))
+	}
+	root, children := writeSharedIncludeTree(b, envs)
+	gitRoot = root
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx := startRun(context.Background())
+		for _, child := range children {
+			if _, err := parseLocals(parsingContextFor(b, ctx, child), child, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestWithoutKeepGoing(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples_errors", "keep_going"),
+	})
+	err = rootCmd.Execute()
+
+	var partialFailure *partialFailureError
+	if err == nil || errors.As(err, &partialFailure) {
+		t.Errorf("Expected the run to stop at the first failure, got '%v'", err)
+	}
+}
+
+func TestDiagnosticsJSON(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.json", rand.Int()))
+	defer os.Remove(filename)
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "nested_atlantis_local"),
+		"--diagnostics-format",
+		"json",
+		"--diagnostics-output",
+		filename,
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	goldenContent, err := os.ReadFile(filepath.Join("golden", "diagnostics.json"))
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	assert.JSONEq(t, string(goldenContent), string(content))
+}
+
+func TestDiagnosticsSARIF(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.sarif", rand.Int()))
+	defer os.Remove(filename)
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples_errors", "keep_going"),
+		"--keep-going",
+		"--diagnostics-format",
+		"sarif",
+		"--diagnostics-output",
+		filename,
+	})
+	rootCmd.Execute()
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	document := sarifLog{}
+	if err := json.Unmarshal(content, &document); err != nil {
+		t.Error(err)
+		return
+	}
+
+	assert.Equal(t, "2.1.0", document.Version)
+	assert.Equal(t, len(diagnosticRules), len(document.Runs[0].Tool.Driver.Rules))
+
+	results := document.Runs[0].Results
+	if assert.Equal(t, 2, len(results)) {
+		assert.Equal(t, "project-failed", results[0].RuleID)
+		assert.Equal(t, "error", results[0].Level)
+		assert.Equal(t, "broken/terragrunt.hcl", results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+		assert.Equal(t, &sarifRegion{StartLine: 6, StartColumn: 12, EndLine: 6, EndColumn: 25}, results[0].Locations[0].PhysicalLocation.Region)
+
+		assert.Equal(t, "unparseable-config", results[1].RuleID)
+		assert.Equal(t, "warning", results[1].Level)
+		assert.Equal(t, "shared/config.hcl", results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	}
+}
+
+func TestDiagnosticsWithUnsupportedFormat(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--diagnostics-format",
+		"xml",
+	})
+	err = rootCmd.Execute()
+
+	expectedError := `unsupported diagnostics format "xml", must be one of "json" or "sarif"`
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
+
+func TestPreservingOldWorkflows(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+
+	// Create an existing file to simulate an existing atlantis.yaml file
+	contents := []byte(`workflows:
+  terragrunt:
+    apply:
+      steps:
+      - run: terragrunt apply -no-color $PLANFILE
+    plan:
+      steps:
+      - run: terragrunt plan -no-color -out $PLANFILE
+`)
+	os.WriteFile(filename, contents, 0644)
+
+	content, err := RunWithFlags(filename, []string{
+		"generate",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+	})
+	if err != nil {
+		t.Error("Failed to read file")
+		return
+	}
+
+	goldenContents, err := os.ReadFile(filepath.Join("golden", "oldWorkflowsPreserved.yaml"))
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	if string(content) != string(goldenContents) {
+		t.Errorf("Content did not match golden file.\n\nExpected Content: %s\n\nContent: %s", string(goldenContents), string(content))
+	}
+}
+
+func TestPreservingOldProjects(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+
+	// Create an existing file to simulate an existing atlantis.yaml file
+	contents := []byte(`projects:
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+    - '*.tf*'
+  dir: someDir
+  name: projectFromPreviousRun
+`)
+	os.WriteFile(filename, contents, 0644)
+
+	content, err := RunWithFlags(filename, []string{
+		"generate",
+		"--preserve-projects",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+	})
+	if err != nil {
+		t.Error("Failed to read file")
+		return
+	}
+
+	goldenContents, err := os.ReadFile(filepath.Join("golden", "oldProjectsPreserved.yaml"))
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	if string(content) != string(goldenContents) {
+		t.Errorf("Content did not match golden file.\n\nExpected Content: %s\n\nContent: %s", string(goldenContents), string(content))
+	}
+}
+
+func TestEnablingAutomerge(t *testing.T) {
+	runTest(t, filepath.Join("golden", "withAutomerge.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--automerge",
+	})
+}
+
+func TestChainedDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "chained_dependency.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cascade-dependencies",
+	})
+}
+
+func TestChainedDependenciesHiddenBehindFlag(t *testing.T) {
+	runTest(t, filepath.Join("golden", "chained_dependency_no_flag.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cascade-dependencies=false",
+	})
+}
+
+func TestApplyRequirementsLocals(t *testing.T) {
+	runTest(t, filepath.Join("golden", "apply_overrides.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "apply_requirements_overrides"),
+	})
+}
+
+func TestApplyRequirementsFlag(t *testing.T) {
+	runTest(t, filepath.Join("golden", "apply_overrides_flag.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--apply-requirements=approved,mergeable",
+	})
+}
+
+func TestFilterFlagWithInfraLiveProd(t *testing.T) {
+	runTest(t, filepath.Join("golden", "filterInfraLiveProd.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod"),
+	})
+}
+
+func TestFilterFlagWithInfraLiveNonProd(t *testing.T) {
+	runTest(t, filepath.Join("golden", "filterInfraLiveNonProd.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "non-prod"),
+	})
+}
+
+func TestFilterFlagWithInfraLiveProdAndNonProd(t *testing.T) {
+	runTest(t, filepath.Join("golden", "filterInfraLiveProdAndNonProd.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter",
+		strings.Join(
+			[]string{
+				filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "non-prod"),
+				filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod"),
+			},
+			",",
+		),
+	})
+}
+
+func TestFilterGlobFlagWithInfraLiveMySql(t *testing.T) {
+	runTest(t, filepath.Join("golden", "filterGlobInfraLiveMySQL.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "*", "*", "*", "mysql"),
+	})
+}
+
+func TestMultipleIncludes(t *testing.T) {
+	runTest(t, filepath.Join("golden", "multiple_includes.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "multiple_includes"),
+		"--terraform-version", "0.14.9001",
+	})
+}
+
+func TestRemoteModuleSourceBitbucket(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_bitbucket"),
+	})
+}
+
+func TestRemoteModuleSourceGCS(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_gcs"),
+	})
+}
+
+func TestRemoteModuleSourceGitHTTPS(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_git_https"),
+	})
+}
+
+func TestRemoteModuleSourceGitSCPLike(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_git_scp_like"),
+	})
+}
+
+func TestRemoteModuleSourceGitSSH(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_git_ssh"),
+	})
+}
+
+func TestRemoteModuleSourceGithubHTTPS(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_github_https"),
+	})
+}
+
+func TestRemoteModuleSourceGithubSSH(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_github_ssh"),
+	})
+}
+
+func TestRemoteModuleSourceHTTP(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_http"),
+	})
+}
+
+func TestRemoteModuleSourceHTTPS(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_https"),
+	})
+}
+
+func TestRemoteModuleSourceMercurial(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_mercurial"),
+	})
+}
+
+func TestRemoteModuleSourceS3(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_s3"),
+	})
+}
+
+func TestRemoteModuleSourceTerraformRegistry(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_terraform_registry"),
+	})
+}
+
+func TestEnvHCLProjectsNoChilds(t *testing.T) {
+	runTest(t, filepath.Join("golden", "envhcl_nochilds.yaml"), []string{
+		"--root",
 		filepath.Join("..", "test_examples"),
 		"--project-hcl-files=env.hcl",
 		"--create-hcl-project-childs=false",
@@ -620,6 +1657,18 @@ func TestEnvHCLProjectsSubChilds(t *testing.T) {
 	})
 }
 
+// Projects of every working dir share the executors, so a single executor must still get through all of them
+func TestEnvHCLProjectsSubChildsWithOneExecutor(t *testing.T) {
+	runTest(t, filepath.Join("golden", "envhcl_subchilds.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples"),
+		"--project-hcl-files=env.hcl",
+		"--create-hcl-project-childs=true",
+		"--create-hcl-project-external-childs=false",
+		"--num-executors=1",
+	})
+}
+
 func TestEnvHCLProjectsExternalChilds(t *testing.T) {
 	runTest(t, filepath.Join("golden", "envhcl_externalchilds.yaml"), []string{
 		"--root",
@@ -640,6 +1689,16 @@ func TestEnvHCLProjectsAllChilds(t *testing.T) {
 	})
 }
 
+// Projects of project hcl files get the version pinned above them, or else the one required by the modules of their children
+func TestEnvHCLProjectsDetectingTerraformVersion(t *testing.T) {
+	runTest(t, filepath.Join("golden", "project_hcl_version_detection.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "project_hcl_version_detection"),
+		"--project-hcl-files=project.hcl",
+		"--detect-terraform-version",
+	})
+}
+
 func TestEnvHCLProjectMarker(t *testing.T) {
 	runTest(t, filepath.Join("golden", "project_marker.yaml"), []string{
 		"--root",
@@ -682,3 +1741,530 @@ func TestWithDependsOn(t *testing.T) {
 		"--create-project-name",
 	})
 }
+
+func TestWithoutExclusions(t *testing.T) {
+	runTest(t, filepath.Join("golden", "exclusions.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "exclusions"),
+	})
+}
+
+// Excluded configs get no projects, and excluded local modules are left out of when_modified
+func TestExclusions(t *testing.T) {
+	runTest(t, filepath.Join("golden", "exclusions_excluded.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "exclusions"),
+		"--exclude",
+		"vendor",
+		"--use-ignore-files",
+	})
+}
+
+// Ignore files follow git: `dir/**` leaves `dir` itself alone so paths in it can be re-included, and dir-only
+// patterns without a slash match at any depth. Paths outside of the root are never excluded.
+func TestIgnoreFilePatterns(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	root := t.TempDir()
+	ignoreFile := "generated/**\n!generated/keep\nscratch/\n"
+	if err := os.WriteFile(filepath.Join(root, ".atlantisignore"), []byte(ignoreFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitRoot = root
+	useIgnoreFiles = true
+	ctx := startRun(context.Background())
+
+	cases := []struct {
+		path     string
+		isDir    bool
+		excluded bool
+	}{
+		{"generated", true, false},
+		{"generated/app", true, true},
+		{"generated/keep", true, false},
+		{"generated/keep/main.tf", false, true},
+		{"scratch/terragrunt.hcl", false, true},
+		{"live/scratch/main.tf", false, true},
+		{"live/scratch", false, false},
+		{"live/app/terragrunt.hcl", false, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.excluded, isExcludedPath(ctx, filepath.Join(root, c.path), c.isDir), c.path)
+	}
+
+	// Names starting with `..` are inside the root
+	excludePatterns = []string{"..scratch"}
+	assert.True(t, isExcludedPath(ctx, filepath.Join(root, "..scratch", "terragrunt.hcl"), false))
+	assert.False(t, isExcludedPath(ctx, filepath.Join(root, "..", "..scratch", "terragrunt.hcl"), false))
+}
+
+func TestExclusionsWithInvalidPattern(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "exclusions"),
+		"--exclude",
+		"vendor/[",
+	})
+	err = rootCmd.Execute()
+
+	expectedError := `invalid --exclude pattern "vendor/[": syntax error in pattern`
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
+
+func TestWithoutFollowingSymlinks(t *testing.T) {
+	runTest(t, filepath.Join("golden", "symlinks.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "symlinks"),
+	})
+}
+
+// Configs behind symlinked directories get projects at their link path, depending on their real files,
+// while symlinks leading back to a parent directory are skipped
+func TestFollowingSymlinks(t *testing.T) {
+	runTest(t, filepath.Join("golden", "symlinks_followed.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "symlinks"),
+		"--follow-symlinks",
+	})
+}
+
+// Creates the given files below `root`, along with their directories
+func writeIndexTree(t *testing.T, root string, files []string) {
+	for _, file := range files {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte{}, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Builds an index of a temporary tree, with the root as the git root so exclusions apply to it
+func buildTestIndex(t *testing.T, files []string) (string, *repositoryIndex) {
+	if err := resetForRun(); err != nil {
+		t.Fatal("Failed to reset default flags")
+	}
+	root := t.TempDir()
+	writeIndexTree(t, root, files)
+	gitRoot = root
+
+	index, err := buildRepositoryIndex(startRun(context.Background()), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, index
+}
+
+var indexTestFiles = []string{
+	"root.hcl",
+	"a/terragrunt.hcl",
+	"a/main.tf",
+	"a/c/terragrunt.hcl",
+	"a-b/terragrunt.hcl",
+	"b/terragrunt.hcl",
+	"b/d/e/vars.tofu",
+}
+
+// The index is sorted in the order `filepath.Walk` visits directories, however many executors read them
+func TestRepositoryIndexWalkOrder(t *testing.T) {
+	for _, executors := range []int64{1, 2, 15} {
+		t.Run(fmt.Sprintf("%d executors", executors), func(t *testing.T) {
+			root, _ := buildTestIndex(t, indexTestFiles)
+			numExecutors = executors
+			index, err := buildRepositoryIndex(startRun(context.Background()), root)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			walked := []string{}
+			err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if info.IsDir() {
+					walked = append(walked, path)
+				}
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, walked, index.dirs)
+			assert.Equal(t, []string{
+				filepath.Join(root, "root.hcl"),
+				filepath.Join(root, "a", "terragrunt.hcl"),
+				filepath.Join(root, "a", "c", "terragrunt.hcl"),
+				filepath.Join(root, "a-b", "terragrunt.hcl"),
+				filepath.Join(root, "b", "terragrunt.hcl"),
+			}, index.configFilesIn(root))
+			assert.Equal(t, []string{
+				filepath.Join(root, "a", "terragrunt.hcl"),
+				filepath.Join(root, "a", "c", "terragrunt.hcl"),
+			}, index.configFilesIn(filepath.Join(root, "a")))
+		})
+	}
+}
+
+func TestRepositoryIndexDirsWithFile(t *testing.T) {
+	root, index := buildTestIndex(t, indexTestFiles)
+
+	assert.Equal(t, []string{
+		filepath.Join(root, "a"),
+		filepath.Join(root, "a", "c"),
+		filepath.Join(root, "a-b"),
+		filepath.Join(root, "b"),
+	}, index.dirsWithFile("terragrunt.hcl"))
+	assert.Equal(t, []string{filepath.Join(root, "a")}, index.dirsWithFile("main.tf"))
+	assert.Equal(t, []string{}, index.dirsWithFile("missing.hcl"))
+
+	// Directories named like the file don't count
+	root, index = buildTestIndex(t, []string{"a/terragrunt.hcl/main.tf"})
+	assert.Equal(t, []string{filepath.Join(root, "a", "terragrunt.hcl")}, index.dirsWithFile("main.tf"))
+	assert.Equal(t, []string{}, index.configFilesIn(root))
+}
+
+func TestRepositoryIndexExclusions(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Fatal("Failed to reset default flags")
+	}
+	root := t.TempDir()
+	writeIndexTree(t, root, append([]string{
+		".terragrunt-cache/abc/terragrunt.hcl",
+		"vendor/terragrunt.hcl",
+		"a/ignored.tf",
+	}, indexTestFiles...))
+	gitRoot = root
+	excludePatterns = []string{"vendor", "a/ignored.tf"}
+
+	index, err := buildRepositoryIndex(startRun(context.Background()), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{
+		root,
+		filepath.Join(root, "a"),
+		filepath.Join(root, "a", "c"),
+		filepath.Join(root, "a-b"),
+		filepath.Join(root, "b"),
+		filepath.Join(root, "b", "d"),
+		filepath.Join(root, "b", "d", "e"),
+	}, index.dirs)
+	assert.Equal(t, map[string]bool{"terragrunt.hcl": true, "main.tf": true}, index.files[filepath.Join(root, "a")])
+	assert.True(t, index.moduleDirs[filepath.Join(root, "b", "d", "e")])
+	assert.False(t, index.moduleDirs[filepath.Join(root, "b")])
+}
+
+// Stack files are recorded in walk order, leaving out excluded ones and directories named like them
+func TestRepositoryIndexStackFiles(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Fatal("Failed to reset default flags")
+	}
+	root := t.TempDir()
+	writeIndexTree(t, root, append([]string{
+		"b/terragrunt.stack.hcl",
+		"a-b/terragrunt.stack.hcl",
+		"a/c/terragrunt.stack.hcl",
+		"vendor/terragrunt.stack.hcl",
+		"d/terragrunt.stack.hcl/main.tf",
+	}, indexTestFiles...))
+	gitRoot = root
+	excludePatterns = []string{"vendor"}
+
+	index, err := buildRepositoryIndex(startRun(context.Background()), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{
+		filepath.Join(root, "a", "c", "terragrunt.stack.hcl"),
+		filepath.Join(root, "a-b", "terragrunt.stack.hcl"),
+		filepath.Join(root, "b", "terragrunt.stack.hcl"),
+	}, index.stackFiles)
+}
+
+// Directories that can't be read are left out of the index, instead of failing the run
+func TestRepositoryIndexSkipsUnreadableDirectories(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("directory permissions don't apply to root")
+	}
+
+	root, _ := buildTestIndex(t, indexTestFiles)
+	locked := filepath.Join(root, "a")
+	if err := os.Chmod(locked, 0o000); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0o755)
+
+	index, err := buildRepositoryIndex(startRun(context.Background()), root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{
+		root,
+		filepath.Join(root, "a-b"),
+		filepath.Join(root, "b"),
+		filepath.Join(root, "b", "d"),
+		filepath.Join(root, "b", "d", "e"),
+	}, index.dirs)
+}
+
+func TestWithoutSandbox(t *testing.T) {
+	runTest(t, filepath.Join("golden", "sandbox_unsandboxed.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "sandbox"),
+	})
+}
+
+func TestSandbox(t *testing.T) {
+	runTest(t, filepath.Join("golden", "sandbox.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "sandbox"),
+		"--sandbox",
+	})
+}
+
+func TestSandboxValues(t *testing.T) {
+	runTest(t, filepath.Join("golden", "sandbox_unsandboxed.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "sandbox"),
+		"--sandbox",
+		"--sandbox-value",
+		"run_cmd=vars.yaml",
+	})
+}
+
+func TestSandboxValueOfUnknownFunction(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "sandbox"),
+		"--sandbox",
+		"--sandbox-value",
+		"get_env=prod",
+	})
+	err = rootCmd.Execute()
+
+	expectedError := `unknown sandboxed function "get_env", must be one of: get_aws_account_alias, get_aws_account_id, get_aws_caller_identity_arn, get_aws_caller_identity_user_id, get_working_dir, run_cmd, sops_decrypt_file`
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
+
+func TestLintSandboxesByDefault(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"lint",
+		"--root",
+		filepath.Join("..", "test_examples", "sandbox"),
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	calls := []string{}
+	latestRun.sandboxedCalls.Range(func(key, _ interface{}) bool {
+		calls = append(calls, key.(string))
+		return true
+	})
+	if assert.Equal(t, 1, len(calls)) {
+		assert.True(t, strings.HasSuffix(filepath.ToSlash(calls[0]), `sandbox/app/terragrunt.hcl|run_cmd("--terragrunt-quiet", "echo", "vars.yaml")`))
+	}
+}
+
+// get_working_dir parses the config again with the functions of terragrunt, so it must not escape the sandbox
+func TestSandboxGetWorkingDir(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	marker := filepath.Join(t.TempDir(), "marker")
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples_errors", "sandbox_working_dir"),
+		"--output",
+		filepath.Join(t.TempDir(), "atlantis.yaml"),
+		"--sandbox",
+		"--env",
+		"SANDBOX_MARKER=" + marker,
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = os.Stat(marker)
+	assert.True(t, os.IsNotExist(err), "run_cmd ran outside of the sandbox")
+}
+
+func TestCleanEnv(t *testing.T) {
+	t.Setenv("ATLANTIS_CONFIG_STAGE", "prod")
+	runTest(t, filepath.Join("golden", "env_vars.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "env_vars"),
+		"--clean-env",
+	})
+}
+
+func TestEnvFile(t *testing.T) {
+	runTest(t, filepath.Join("golden", "env_vars_file.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "env_vars"),
+		"--clean-env",
+		"--env-file",
+		filepath.Join("..", "test_examples", "env_vars", "prod.env"),
+	})
+}
+
+func TestEnvOverridesEnvFile(t *testing.T) {
+	runTest(t, filepath.Join("golden", "env_vars_flag.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "env_vars"),
+		"--env-file",
+		filepath.Join("..", "test_examples", "env_vars", "prod.env"),
+		"--env",
+		"ATLANTIS_CONFIG_STAGE=qa",
+	})
+}
+
+func TestEnvVarsReadAreReported(t *testing.T) {
+	runTest(t, filepath.Join("golden", "env_vars.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "env_vars"),
+		"--clean-env",
+	})
+
+	messages := []string{}
+	for _, d := range latestRun.diagnostics.list() {
+		if d.ruleID == ruleEnvVarsRead {
+			messages = append(messages, d.message)
+		}
+	}
+	assert.Equal(t, []string{"read env vars ATLANTIS_CONFIG_STAGE (unset)"}, messages)
+}
+
+func TestInvalidEnv(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "env_vars"),
+		"--env",
+		"ATLANTIS_CONFIG_STAGE",
+	})
+	err = rootCmd.Execute()
+
+	expectedError := `invalid --env "ATLANTIS_CONFIG_STAGE", expected KEY=VALUE`
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
+
+// `get_env` takes a name and an optional default, and fails on a required env var that isn't set, like in terragrunt
+func TestGetEnvironmentVariable(t *testing.T) {
+	env := map[string]string{"STAGE": "prod", "EMPTY": ""}
+
+	value, err := getEnvironmentVariable(env, []string{"STAGE"})
+	assert.NoError(t, err)
+	assert.Equal(t, "prod", value)
+
+	value, err = getEnvironmentVariable(env, []string{"EMPTY", "default"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", value)
+
+	value, err = getEnvironmentVariable(env, []string{"REGION", "us-east-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1", value)
+
+	_, err = getEnvironmentVariable(env, []string{"REGION"})
+	assert.ErrorContains(t, err, "Required environment variable REGION - not found")
+
+	_, err = getEnvironmentVariable(env, []string{""})
+	assert.ErrorContains(t, err, "Invalid environment variable name")
+
+	_, err = getEnvironmentVariable(env, []string{"STAGE", "a", "b"})
+	assert.ErrorContains(t, err, "Expected one or two parameters")
+	_, err = getEnvironmentVariable(env, []string{})
+	assert.ErrorContains(t, err, "Expected one or two parameters")
+}
+
+func TestFeatureFlagDefaults(t *testing.T) {
+	runTest(t, filepath.Join("golden", "feature_flags.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "feature_flags"),
+	})
+}
+
+func TestFeatureFlagOverrides(t *testing.T) {
+	runTest(t, filepath.Join("golden", "feature_flags_override.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "feature_flags"),
+		"--feature",
+		"use_legacy_vpc=true",
+	})
+}
+
+func TestFeatureMatrix(t *testing.T) {
+	runTest(t, filepath.Join("golden", "feature_flags_matrix.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "feature_flags"),
+		"--feature-matrix",
+	})
+}
+
+func TestFeatureGivenTwiceWithoutMatrix(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "feature_flags"),
+		"--feature",
+		"use_legacy_vpc=true",
+		"--feature",
+		"use_legacy_vpc=false",
+	})
+	err = rootCmd.Execute()
+
+	expectedError := `feature "use_legacy_vpc" is given more than once, which needs --feature-matrix`
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
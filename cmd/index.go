@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/terragrunt/util"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// The name of terragrunt stack files
+const stackFilename = "terragrunt.stack.hcl"
+
+// An index of the directories below a root, built with a single concurrent walk and shared by every phase
+// of a run, so the tree is only read once no matter how many working dirs and project hcl files there are
+type repositoryIndex struct {
+	// The absolute path the index was built from
+	root string
+
+	// The directories that aren't excluded, in the order `filepath.Walk` would visit them
+	dirs []string
+
+	// The files of each directory, keyed by the directory's absolute path
+	files map[string]map[string]bool
+
+	// The root.hcl and terragrunt config of each directory that has one
+	rootConfigFiles map[string]string
+	configFiles     map[string]string
+
+	// The terragrunt stack files, in walk order
+	stackFiles []string
+
+	// The directories with terraform or OpenTofu files
+	moduleDirs map[string]bool
+}
+
+// Builds an index of `root`, reading directories concurrently with at most `--num-executors` at a time.
+// Excluded paths are left out, see `isExcludedPath`, and so are the directories below the root that can't be
+// read. Symlinked directories are only followed with `--follow-symlinks`, and are indexed under their link path.
+func buildRepositoryIndex(ctx context.Context, root string) (*repositoryIndex, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &repositoryIndex{
+		root:            root,
+		files:           map[string]map[string]bool{},
+		rootConfigFiles: map[string]string{},
+		configFiles:     map[string]string{},
+		moduleDirs:      map[string]bool{},
+	}
+	mtx := sync.Mutex{}
+
+	group := errgroup.Group{}
+	if numExecutors > 0 {
+		group.SetLimit(int(numExecutors))
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+
+	// `ancestors` holds the real paths of the directories above `dir`, so symlinks leading back to any
+	// of them aren't followed
+	var visit func(dir string, ancestors []string) error
+	visit = func(dir string, ancestors []string) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if dir == root {
+				return err
+			}
+			log.Warn("Skipping directory ", dir, " as it can't be read: ", err)
+			return nil
+		}
+
+		realDir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			return err
+		}
+		ancestors = append(append([]string{}, ancestors...), realDir)
+
+		files := map[string]bool{}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			isDir := entry.IsDir()
+			if isSymlinkToDir(path, entry) {
+				if !followSymlinks {
+					continue
+				}
+				target, err := filepath.EvalSymlinks(path)
+				if err != nil {
+					return err
+				}
+				if containsString(ancestors, target) {
+					log.Warn("Not following symlink ", path, " as it leads back to ", target)
+					continue
+				}
+				if !isPathIn(target, realRoot) {
+					log.Debug("Not following symlink ", path, " as it leads out of the root to ", target)
+					continue
+				}
+				isDir = true
+			}
+
+			if isDir {
+				if isExcludedPath(ctx, path, true) {
+					continue
+				}
+				// Read the subdirectory in this goroutine when all executors are busy
+				if !group.TryGo(func() error { return visit(path, ancestors) }) {
+					if err := visit(path, ancestors); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if !isExcludedPath(ctx, path, false) {
+				files[entry.Name()] = true
+			}
+		}
+
+		mtx.Lock()
+		defer mtx.Unlock()
+		index.addDir(dir, files)
+		return nil
+	}
+
+	if err := visit(root, nil); err != nil {
+		return nil, err
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(index.dirs, func(i, j int) bool { return walkOrderLess(index.dirs[i], index.dirs[j]) })
+	sort.Slice(index.stackFiles, func(i, j int) bool { return walkOrderLess(index.stackFiles[i], index.stackFiles[j]) })
+	log.Debugf("Indexed %d directories with %d configs and %d stack files", len(index.dirs), len(index.configFiles), len(index.stackFiles))
+	return index, nil
+}
+
+// Records the files of `dir`, and the configs, stack file and terraform files among them
+func (index *repositoryIndex) addDir(dir string, files map[string]bool) {
+	index.dirs = append(index.dirs, dir)
+	index.files[dir] = files
+
+	isFile := func(name string) bool {
+		return files[name] && !util.IsDir(filepath.Join(dir, name))
+	}
+	if isFile("root.hcl") {
+		index.rootConfigFiles[dir] = filepath.Join(dir, "root.hcl")
+	}
+	for _, name := range configFileNames() {
+		if isFile(name) {
+			index.configFiles[dir] = filepath.Join(dir, name)
+			break
+		}
+	}
+	if isFile(stackFilename) {
+		index.stackFiles = append(index.stackFiles, filepath.Join(dir, stackFilename))
+	}
+	for name := range files {
+		if isTerraformFile(name) {
+			index.moduleDirs[dir] = true
+			break
+		}
+	}
+}
+
+// Checks if `path` is the root of the index or below it
+func (index *repositoryIndex) covers(path string) bool {
+	return path == index.root || strings.HasPrefix(path, index.root+string(filepath.Separator))
+}
+
+// Returns the directories of the index that are `path` or below it, in walk order
+func (index *repositoryIndex) dirsIn(path string) []string {
+	path = filepath.Clean(path)
+	dirs := []string{}
+	for _, dir := range index.dirs {
+		if dir == path || strings.HasPrefix(dir, path+string(filepath.Separator)) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Returns the terragrunt configs in `path` or any directory below it, with root.hcl files first
+func (index *repositoryIndex) configFilesIn(path string) []string {
+	rootConfigFiles := []string{}
+	configFiles := []string{}
+	for _, dir := range index.dirsIn(path) {
+		if rootConfigFile, ok := index.rootConfigFiles[dir]; ok {
+			rootConfigFiles = append(rootConfigFiles, rootConfigFile)
+		}
+		if configFile, ok := index.configFiles[dir]; ok {
+			configFiles = append(configFiles, configFile)
+		}
+	}
+	return append(rootConfigFiles, configFiles...)
+}
+
+// Returns the directories with a file named `name`, in walk order
+func (index *repositoryIndex) dirsWithFile(name string) []string {
+	dirs := []string{}
+	for _, dir := range index.dirs {
+		if index.files[dir][name] {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Returns the index to look up the absolute `path` in: the index of the run of `ctx` when it covers the path, or
+// else a new index of the path, as with `--filter` globs outside of the root
+func repositoryIndexFor(ctx context.Context, path string) (*repositoryIndex, error) {
+	if index := runOf(ctx).index; index != nil && index.covers(path) {
+		return index, nil
+	}
+	return buildRepositoryIndex(ctx, path)
+}
+
+// Checks if the file at the absolute `path` exists, using the index of the run of `ctx` when it covers the path
+func indexedFileExists(ctx context.Context, path string) bool {
+	dir := filepath.Dir(path)
+	index := runOf(ctx).index
+	if index == nil || !index.covers(dir) {
+		return !util.IsDir(path) && util.FileExists(path)
+	}
+	return index.files[dir][filepath.Base(path)] && !util.IsDir(path)
+}
+
+// Checks if the directory at the absolute `dir` has terraform or OpenTofu files, using the index of the run
+// of `ctx` when it covers the directory
+func hasTerraformFiles(ctx context.Context, dir string) bool {
+	index := runOf(ctx).index
+	if index == nil || !index.covers(dir) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return false
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && isTerraformFile(entry.Name()) {
+				return true
+			}
+		}
+		return false
+	}
+	return index.moduleDirs[dir]
+}
+
+// Checks if a file name is a terraform or OpenTofu file
+func isTerraformFile(name string) bool {
+	for _, suffix := range []string{".tf", ".tf.json", ".tofu", ".tofu.json"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Orders paths like `filepath.Walk` visits them: segment by segment, so a directory's contents come before
+// its siblings that share its name as a prefix
+func walkOrderLess(a string, b string) bool {
+	aSegments := strings.Split(a, string(filepath.Separator))
+	bSegments := strings.Split(b, string(filepath.Separator))
+	for i := 0; i < len(aSegments) && i < len(bSegments); i++ {
+		if aSegments[i] != bSegments[i] {
+			return aSegments[i] < bSegments[i]
+		}
+	}
+	return len(aSegments) < len(bSegments)
+}
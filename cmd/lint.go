@@ -0,0 +1,451 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// A problem found while linting the atlantis locals of a config
+type lintProblem struct {
+	// Where in the config the problem is
+	Range hcl.Range
+
+	// What the problem is
+	Message string
+}
+
+// Formats a problem as `file:line:column: message`, with the file relative to the root
+func (p lintProblem) String() string {
+	filename := p.Range.Filename
+	if relative, err := filepath.Rel(gitRoot, filename); err == nil {
+		filename = filepath.ToSlash(relative)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", filename, p.Range.Start.Line, p.Range.Start.Column, p.Message)
+}
+
+// Lints a set of configs, keeping track of the files already checked so each is only reported once
+type linter struct {
+	ctx      context.Context
+	checked  map[string]bool
+	problems []lintProblem
+}
+
+// The prefixed locals this tool reads, along with the type their value must have
+func atlantisLocalTypes() map[string]cty.Type {
+	types := map[string]cty.Type{}
+	for _, attribute := range atlantisLocalSchema {
+		types[attribute.local] = attribute.ty
+	}
+	return types
+}
+
+// Lints every terragrunt config below the root, along with the configs they include and their sidecar files
+func lintRepository(ctx context.Context) ([]lintProblem, error) {
+	terragruntFiles, err := getAllTerragruntFiles(ctx, gitRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &linter{ctx: ctx, checked: map[string]bool{}}
+	for _, path := range terragruntFiles {
+		opts, err := options.NewTerragruntOptionsWithConfigPath(path)
+		if err != nil {
+			return nil, err
+		}
+		opts.OriginalTerragruntConfigPath = path
+		opts.Env = getEnvs(ctx)
+		applyFeatureFlags(ctx, opts, nil)
+		parsingContext := newParsingContext(ctx, opts)
+
+		l.lintConfig(parsingContext, path, nil)
+		for _, sidecar := range findSidecarFiles(ctx, path) {
+			l.lintSidecarFile(parsingContext, sidecar)
+		}
+	}
+
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		a, b := l.problems[i].Range, l.problems[j].Range
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Start.Byte < b.Start.Byte
+	})
+	return l.problems, nil
+}
+
+func (l *linter) report(rng hcl.Range, format string, args ...interface{}) {
+	l.problems = append(l.problems, lintProblem{Range: rng, Message: fmt.Sprintf(format, args...)})
+}
+
+// Reports an error that happened while reading a config, keeping the ranges of HCL diagnostics
+func (l *linter) reportError(path string, err error) {
+	if diags, ok := err.(hcl.Diagnostics); ok {
+		for _, diag := range diags.Errs() {
+			if hclDiag, ok := diag.(*hcl.Diagnostic); ok && hclDiag.Subject != nil {
+				l.report(*hclDiag.Subject, "%s: %s", hclDiag.Summary, hclDiag.Detail)
+				continue
+			}
+			l.report(hcl.Range{Filename: path, Start: hcl.InitialPos, End: hcl.InitialPos}, "%s", diag.Error())
+		}
+		return
+	}
+	l.report(hcl.Range{Filename: path, Start: hcl.InitialPos, End: hcl.InitialPos}, "%s", err.Error())
+}
+
+// Lints the locals of a config and of every config it includes. Included configs are evaluated in the
+// context of the first child that includes them.
+func (l *linter) lintConfig(ctx *config.ParsingContext, path string, includeFromChild *config.IncludeConfig) {
+	path = filepath.Clean(path)
+	if l.checked[path] {
+		return
+	}
+	l.checked[path] = true
+
+	file, err := readConfigFile(ctx, path)
+	if err != nil {
+		l.reportError(path, err)
+		return
+	}
+
+	includes, err := decodeAsTerragruntInclude(ctx, file.File, path)
+	if err != nil {
+		l.reportError(path, err)
+		return
+	}
+	if len(includes) > 0 {
+		includeFromChild = nil
+	}
+
+	baseBlocks, err := config.DecodeBaseBlocks(ctx, file, includeFromChild)
+	if err != nil {
+		l.reportError(path, err)
+		return
+	}
+
+	locals := map[string]cty.Value{}
+	if baseBlocks.Locals != nil && *baseBlocks.Locals != cty.NilVal {
+		locals = baseBlocks.Locals.AsValueMap()
+	}
+	l.lintLocals(file.File, locals)
+
+	if baseBlocks.TrackInclude != nil && includeFromChild == nil {
+		for _, includeConfig := range baseBlocks.TrackInclude.CurrentList {
+			includeConfig := includeConfig
+			includePath := includeConfig.Path
+			if !filepath.IsAbs(includePath) {
+				includePath = filepath.Join(filepath.Dir(path), includePath)
+			}
+			l.lintConfig(ctx, includePath, &includeConfig)
+		}
+	}
+}
+
+// Checks the evaluated locals of a config against the attributes of its `locals` blocks
+func (l *linter) lintLocals(file *hcl.File, locals map[string]cty.Value) {
+	content, _, _ := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "locals"}},
+	})
+	if content == nil {
+		return
+	}
+
+	localTypes := atlantisLocalTypes()
+	for _, block := range content.Blocks {
+		attributes, diags := block.Body.JustAttributes()
+		if diags.HasErrors() {
+			continue
+		}
+
+		for name, attribute := range attributes {
+			value, ok := locals[name]
+			if !ok {
+				continue
+			}
+
+			if name == "atlantis" {
+				l.lintAtlantisObject(attribute, value)
+				continue
+			}
+
+			ty, known := localTypes[name]
+			if !known {
+				if isAtlantisLocalName(name) {
+					l.report(attribute.NameRange, "unknown local %s%s", name, didYouMean(name, sortedKeys(localTypes)))
+				}
+				continue
+			}
+
+			if message := checkAtlantisLocal(l.ctx, name, ty, value, file.Body.MissingItemRange().Filename); message != "" {
+				l.report(attribute.Expr.Range(), "%s %s", name, message)
+			}
+		}
+	}
+}
+
+// Checks the attributes of the nested `atlantis` local, pointing at each attribute when its position is known
+func (l *linter) lintAtlantisObject(attribute *hcl.Attribute, value cty.Value) {
+	if !value.IsWhollyKnown() {
+		return
+	}
+	if value.IsNull() || (!value.Type().IsObjectType() && !value.Type().IsMapType()) {
+		l.report(attribute.Expr.Range(), "the atlantis local must be an object")
+		return
+	}
+
+	keyRanges := map[string]hcl.Range{}
+	valueRanges := map[string]hcl.Range{}
+	if object, ok := attribute.Expr.(*hclsyntax.ObjectConsExpr); ok {
+		for _, item := range object.Items {
+			if key := hcl.ExprAsKeyword(item.KeyExpr); key != "" {
+				keyRanges[key] = item.KeyExpr.Range()
+				valueRanges[key] = item.ValueExpr.Range()
+			} else if keyValue, diags := item.KeyExpr.Value(nil); !diags.HasErrors() && keyValue.Type() == cty.String {
+				keyRanges[keyValue.AsString()] = item.KeyExpr.Range()
+				valueRanges[keyValue.AsString()] = item.ValueExpr.Range()
+			}
+		}
+	}
+	rangeOf := func(ranges map[string]hcl.Range, key string) hcl.Range {
+		if rng, ok := ranges[key]; ok {
+			return rng
+		}
+		return attribute.Expr.Range()
+	}
+
+	l.lintAtlantisAttributes(value.AsValueMap(), "atlantis.", attribute.Range.Filename, func(key string) hcl.Range {
+		return rangeOf(keyRanges, key)
+	}, func(key string) hcl.Range {
+		return rangeOf(valueRanges, key)
+	})
+}
+
+// Lints a sidecar file, whose top level attributes follow the schema of the nested `atlantis` local
+func (l *linter) lintSidecarFile(ctx *config.ParsingContext, path string) {
+	if l.checked[path] {
+		return
+	}
+	l.checked[path] = true
+
+	file, err := readConfigFile(ctx, path)
+	if err != nil {
+		l.reportError(path, err)
+		return
+	}
+
+	attributes, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		l.reportError(path, diags)
+		return
+	}
+
+	evalContext, err := createTerragruntEvalContext(ctx, path)
+	if err != nil {
+		l.reportError(path, err)
+		return
+	}
+
+	values := map[string]cty.Value{}
+	for name, attribute := range attributes {
+		value, diags := attribute.Expr.Value(evalContext)
+		if diags.HasErrors() {
+			l.reportError(path, diags)
+			continue
+		}
+		values[name] = value
+	}
+
+	l.lintAtlantisAttributes(values, "", path, func(key string) hcl.Range {
+		return attributes[key].NameRange
+	}, func(key string) hcl.Range {
+		return attributes[key].Expr.Range()
+	})
+}
+
+// Checks attributes following the schema of the nested `atlantis` local. `prefix` is how the attributes
+// are referred to in messages.
+func (l *linter) lintAtlantisAttributes(values map[string]cty.Value, prefix string, path string, keyRange func(string) hcl.Range, valueRange func(string) hcl.Range) {
+	schemaKeys := []string{}
+	for key := range atlantisLocalSchema {
+		schemaKeys = append(schemaKeys, key)
+	}
+	sort.Strings(schemaKeys)
+
+	for _, key := range sortedKeys(values) {
+		attribute, ok := atlantisLocalSchema[key]
+		if !ok {
+			l.report(keyRange(key), "unknown attribute %s%s%s", prefix, key, didYouMean(key, schemaKeys))
+			continue
+		}
+
+		if message := checkAtlantisLocal(l.ctx, attribute.local, attribute.ty, values[key], path); message != "" {
+			l.report(valueRange(key), "%s%s %s", prefix, key, message)
+		}
+	}
+}
+
+// Checks that the value of a recognised local has exactly the type it should have, without the conversions
+// the HCL type system would allow, like `"false"` for a bool. Returns an empty string when it is valid.
+func checkAtlantisLocal(ctx context.Context, name string, ty cty.Type, value cty.Value, path string) string {
+	if !value.IsWhollyKnown() {
+		return ""
+	}
+	if message := checkLocalType(ty, value); message != "" {
+		return message
+	}
+
+	// Values of the right type can still be invalid, like an unknown distribution or cascade edge
+	if _, err := resolveLocals(ctx, cty.ObjectVal(map[string]cty.Value{name: value}), path); err != nil {
+		return fmt.Sprintf("is invalid: %s", strings.TrimPrefix(err.Error(), name+": "))
+	}
+	return ""
+}
+
+// Checks if a local looks like it is meant to be read by this tool
+func isAtlantisLocalName(name string) bool {
+	return strings.HasPrefix(name, "atlantis_") || strings.HasPrefix(name, "extra_atlantis_")
+}
+
+// Returns a hint naming the option closest to `name`, or an empty string when none is close enough
+func didYouMean(name string, options []string) string {
+	best := ""
+	bestDistance := 0
+	for _, option := range options {
+		distance := levenshteinDistance(name, option)
+		if best == "" || distance < bestDistance {
+			best = option
+			bestDistance = distance
+		}
+	}
+
+	if best == "" || bestDistance > 3 {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s?", best)
+}
+
+// Returns the number of single character edits needed to turn `a` into `b`
+func levenshteinDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// Returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Prints the problems found while linting, one per line
+func printLintProblems(out io.Writer, problems []lintProblem) {
+	for _, problem := range problems {
+		fmt.Fprintln(out, problem.String())
+	}
+}
+
+// Lints the repository before generating, failing if any problem is found
+func lintStrict(ctx context.Context) error {
+	problems, err := lintRepository(ctx)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+
+	printLintProblems(os.Stderr, problems)
+	return fmt.Errorf("strict mode found %d problem(s) in atlantis locals", len(problems))
+}
+
+// Whether lint runs in sandbox mode, which unlike generate it does by default
+var lintSandbox bool
+
+// lintCmd represents the lint command
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Checks the atlantis locals of terragrunt configs",
+	Long:  "Type-checks every atlantis local of the terragrunt configs below the root, the configs they include and their sidecar files, and reports unknown atlantis_ locals",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		absoluteGitRoot, err := filepath.Abs(gitRoot)
+		if err != nil {
+			return err
+		}
+		gitRoot = absoluteGitRoot + string(filepath.Separator)
+		resolveRealGitRoot()
+		if err := validateExcludePatterns(excludePatterns); err != nil {
+			return err
+		}
+		if err := validateSandboxValues(sandboxValues); err != nil {
+			return err
+		}
+		ctx := startRun(context.Background())
+		if err := loadRunState(ctx, lintSandbox); err != nil {
+			return err
+		}
+
+		problems, err := lintRepository(ctx)
+		if err != nil {
+			return err
+		}
+
+		printLintProblems(cmd.OutOrStdout(), problems)
+		if len(problems) > 0 {
+			return fmt.Errorf("found %d problem(s) in atlantis locals", len(problems))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	lintCmd.Flags().StringVar(&gitRoot, "root", pwd, "Path to the root directory of the git repo you want to lint. Default is current dir")
+	lintCmd.Flags().StringSliceVar(&configFilenames, "config-filename", []string{}, "Name of the terragrunt configs to look for, each also matching its JSON or HCL variant. Can be given more than once, in order of precedence. Default is terragrunt.hcl")
+	lintCmd.Flags().StringSliceVar(&excludePatterns, "exclude", []string{}, "Globs of paths relative to the root that are skipped when looking for configs. A directory that matches excludes everything in it. Default is no patterns")
+	lintCmd.Flags().BoolVar(&useIgnoreFiles, "use-ignore-files", false, "Also excludes the paths ignored by .gitignore and .atlantisignore files. Default is disabled")
+	lintCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follows symlinked directories below the root when looking for configs. Default is disabled")
+	lintCmd.Flags().BoolVar(&lintSandbox, "sandbox", true, "Replaces terragrunt functions with side effects, like run_cmd, sops_decrypt_file and the get_aws_* functions, with stubs that log each distinct call of a config once. Default is enabled, as linting only reads configs")
+	lintCmd.Flags().StringToStringVar(&sandboxValues, "sandbox-value", map[string]string{}, "Fixed values returned by sandboxed functions, as function=value pairs, like get_aws_account_id=123456789012. Default is a placeholder for each function")
+	lintCmd.Flags().StringArrayVar(&envValues, "env", []string{}, "Env var seen by get_env, as KEY=VALUE. Can be given more than once, and overrides --env-file and the process environment")
+	lintCmd.Flags().StringSliceVar(&envFiles, "env-file", []string{}, "Files of KEY=VALUE lines with env vars seen by get_env. Can be given more than once, later files overriding earlier ones and the process environment")
+	lintCmd.Flags().BoolVar(&cleanEnv, "clean-env", false, "Hides the environment of the process from get_env, so it only sees --env and --env-file values. Default is to see the whole environment")
+	lintCmd.Flags().StringArrayVar(&featureValues, "feature", []string{}, "Value of a terragrunt feature flag, as name=value, overriding its default. Can be given more than once")
+	lintCmd.Flags().StringVar(&sidecarFilename, "sidecar-filename", "", "Name of the sidecar files that can hold atlantis settings next to terragrunt configs or in any of their parent directories, like atlantis.hcl. Default is not to read sidecar files")
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The ways a local in a child config can be combined with the same local in its parents
+const (
+	// The child's value, if set, replaces the parent's value
+	mergeStrategyReplace = "replace"
+	// The child's list is appended to the parent's list
+	mergeStrategyAppend = "append"
+	// Lists are appended, while objects are merged attribute by attribute
+	mergeStrategyDeep = "deep"
+)
+
+// The strategies each mergeable local supports, the first one being its default
+var mergeStrategyOptions = map[string][]string{
+	"atlantis_apply_requirements":    {mergeStrategyReplace, mergeStrategyAppend, mergeStrategyDeep},
+	"extra_atlantis_dependencies":    {mergeStrategyAppend, mergeStrategyReplace, mergeStrategyDeep},
+	"atlantis_when_modified_extra":   {mergeStrategyAppend, mergeStrategyReplace, mergeStrategyDeep},
+	"atlantis_when_modified_exclude": {mergeStrategyAppend, mergeStrategyReplace, mergeStrategyDeep},
+	"atlantis_cascade":               {mergeStrategyDeep, mergeStrategyReplace},
+}
+
+// Ensures every key in a set of merge strategies is a mergeable local, and that it supports its strategy
+func validateMergeStrategies(strategies map[string]string) error {
+	for key, strategy := range strategies {
+		options, ok := mergeStrategyOptions[key]
+		if !ok {
+			keys := []string{}
+			for k := range mergeStrategyOptions {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			return fmt.Errorf("unknown merge strategy key %q, must be one of: %s", key, strings.Join(keys, ", "))
+		}
+		if !containsString(options, strategy) {
+			return fmt.Errorf("unsupported merge strategy %q for %s, must be one of: %s", strategy, key, strings.Join(options, ", "))
+		}
+	}
+	return nil
+}
+
+// Returns the strategy to merge the local `key` with, looking at the `atlantis_merge_strategy` local
+// first, then the `--merge-strategy` flag, and finally the default for the key
+func resolveMergeStrategy(strategies map[string]string, key string) string {
+	if strategy, ok := strategies[key]; ok {
+		return strategy
+	}
+	if strategy, ok := mergeStrategies[key]; ok {
+		return strategy
+	}
+	return mergeStrategyOptions[key][0]
+}
+
+// Combines the merge strategies of a parent with those of a child, the child's winning for the same key
+func mergeMergeStrategies(parent map[string]string, child map[string]string) map[string]string {
+	if len(child) == 0 {
+		return parent
+	}
+
+	merged := map[string]string{}
+	for key, strategy := range parent {
+		merged[key] = strategy
+	}
+	for key, strategy := range child {
+		merged[key] = strategy
+	}
+	return merged
+}
+
+// Merges a list local of a child into the same local of a parent
+func mergeListLocal(strategy string, parent []string, child []string) []string {
+	if strategy == mergeStrategyReplace {
+		if child != nil {
+			return child
+		}
+		return parent
+	}
+
+	if child == nil {
+		return parent
+	}
+
+	merged := append([]string{}, parent...)
+	for _, value := range child {
+		if strategy == mergeStrategyAppend || !containsString(merged, value) {
+			merged = append(merged, value)
+		}
+	}
+	return merged
+}
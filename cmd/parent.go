@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/util"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Decides if the config at `path` is a parent from explicit rules, in order:
+//   - the `atlantis_is_parent` local of the config itself
+//   - the `--parent-patterns` globs, matched against the path of the config relative to the root
+//   - with `--parent-when-included`, whether another config includes it
+//
+// Returns nil when no rule applies, so the heuristic in `parseModule` decides instead
+func detectParentConfig(ctx *config.ParsingContext, file *hcl.File, path string) (*bool, error) {
+	isParent, err := readIsParentLocal(ctx, file, path)
+	if err != nil || isParent != nil {
+		return isParent, err
+	}
+
+	isParentValue := true
+	relativePath, err := filepath.Rel(gitRoot, path)
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range parentPatterns {
+		if matched, _ := matchPathGlob(pattern, filepath.ToSlash(relativePath)); matched {
+			return &isParentValue, nil
+		}
+	}
+
+	if parentWhenIncluded && runOf(ctx).includedConfigs[filepath.Clean(path)] {
+		return &isParentValue, nil
+	}
+
+	return nil, nil
+}
+
+// Reads the `atlantis_is_parent` local, or the `is_parent` attribute of the nested `atlantis` local, of a
+// single config. It is read before the rest of the locals are evaluated, so it can't refer to other locals.
+func readIsParentLocal(ctx *config.ParsingContext, file *hcl.File, path string) (*bool, error) {
+	content, _, _ := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "locals"}},
+	})
+	if content == nil {
+		return nil, nil
+	}
+
+	var expr hcl.Expression
+	for _, block := range content.Blocks {
+		attributes, diags := block.Body.JustAttributes()
+		if diags.HasErrors() {
+			continue
+		}
+
+		if attribute, ok := attributes["atlantis_is_parent"]; ok {
+			expr = attribute.Expr
+			break
+		}
+		if attribute, ok := attributes["atlantis"]; ok {
+			if object, ok := attribute.Expr.(*hclsyntax.ObjectConsExpr); ok {
+				for _, item := range object.Items {
+					if hcl.ExprAsKeyword(item.KeyExpr) == "is_parent" {
+						expr = item.ValueExpr
+					}
+				}
+			}
+		}
+	}
+	if expr == nil {
+		return nil, nil
+	}
+
+	evalContext, err := createTerragruntEvalContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	value, diags := expr.Value(evalContext)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	if value.IsNull() || !value.IsKnown() || !value.Type().Equals(cty.Bool) {
+		return nil, fmt.Errorf("%s: atlantis_is_parent must be a bool", path)
+	}
+
+	isParent := value.True()
+	return &isParent, nil
+}
+
+// Finds every config included by another config below the root, following include chains
+func findIncludedConfigs(ctx context.Context) (map[string]bool, error) {
+	terragruntFiles, err := getAllTerragruntFiles(ctx, gitRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	included := map[string]bool{}
+	visited := map[string]bool{}
+	var visit func(path string)
+	visit = func(path string) {
+		path = filepath.Clean(path)
+		if visited[path] {
+			return
+		}
+		visited[path] = true
+
+		opts, err := options.NewTerragruntOptionsWithConfigPath(path)
+		if err != nil {
+			return
+		}
+		opts.Env = getEnvs(ctx)
+		applyFeatureFlags(ctx, opts, nil)
+		parsingContext := newParsingContext(ctx, opts)
+
+		// Configs that can't be parsed are reported when their projects are generated
+		file, err := readConfigFile(parsingContext, path)
+		if err != nil {
+			return
+		}
+		includes, err := decodeAsTerragruntInclude(parsingContext, file.File, path)
+		if err != nil {
+			return
+		}
+
+		for _, include := range includes {
+			includePath := include.Path
+			if !filepath.IsAbs(includePath) {
+				includePath = filepath.Join(filepath.Dir(path), includePath)
+			}
+			included[filepath.Clean(includePath)] = true
+			visit(includePath)
+		}
+	}
+
+	for _, path := range terragruntFiles {
+		visit(path)
+	}
+	return included, nil
+}
+
+// Finds the included configs below the root that aren't found as terragrunt configs, so that
+// `--create-parent-project` can create projects for them too
+func findParentConfigFiles(ctx context.Context) (map[string]bool, error) {
+	terragruntFiles, err := getAllTerragruntFiles(ctx, gitRoot)
+	if err != nil {
+		return nil, err
+	}
+	isTerragruntFile := map[string]bool{}
+	for _, path := range terragruntFiles {
+		isTerragruntFile[filepath.Clean(path)] = true
+	}
+
+	parents := map[string]bool{}
+	for path := range runOf(ctx).includedConfigs {
+		if isTerragruntFile[path] || !strings.HasPrefix(path, gitRoot) || !util.FileExists(path) {
+			continue
+		}
+		parents[path] = true
+	}
+	return parents, nil
+}
+
+// Checks if `name` matches any of the `when_modified` patterns, ignoring invalid patterns
+func matchesAnyPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if two projects are the same when preserving projects. Projects are matched by directory, unless
+// both are named, as parents sharing a directory with other projects are
+func isSameProject(a AtlantisProject, b AtlantisProject) bool {
+	if a.Dir != b.Dir {
+		return false
+	}
+	return a.Name == "" || b.Name == "" || a.Name == b.Name
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/config/hclparse"
+	"github.com/hashicorp/hcl/v2"
+)
+
+// A result computed for a key, along with the error computing it
+type cachedResult[V any] struct {
+	value V
+	err   error
+}
+
+// Caches results by key. Results are computed outside of the lock, so two goroutines missing the same key
+// at once may both compute it, but neither waits on the other, which would deadlock on include cycles.
+type resultCache[V any] struct {
+	mtx  sync.RWMutex
+	data map[string]cachedResult[V]
+}
+
+func newResultCache[V any]() *resultCache[V] {
+	return &resultCache[V]{data: map[string]cachedResult[V]{}}
+}
+
+// Returns the cached result for `key`, or computes it. Errors computing it after `ctx` is done are likely caused
+// by the unit being cut short, so they are not cached.
+func (c *resultCache[V]) get(ctx context.Context, key string, compute func() (V, error)) (V, error) {
+	c.mtx.RLock()
+	result, ok := c.data[key]
+	c.mtx.RUnlock()
+	if ok {
+		return result.value, result.err
+	}
+
+	value, err := compute()
+	if err != nil && ctx.Err() != nil {
+		return value, err
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.data[key] = cachedResult[V]{value: value, err: err}
+	return value, err
+}
+
+// The parsed files and decoded results of a single run, so each file is read and parsed once however many
+// configs include it. Decoded results depend on the config being generated, as terragrunt functions like
+// `find_in_parent_folders` and `path_relative_to_include` do, so they are also keyed by that config and only
+// reused while generating it.
+type parseCache struct {
+	files    *resultCache[*hcl.File]
+	includes *resultCache[[]config.IncludeConfig]
+	locals   *resultCache[ResolvedLocals]
+
+	// The files with bare `include` blocks already labeled, which don't need to be checked again
+	normalized sync.Map
+}
+
+func newParseCache() *parseCache {
+	return &parseCache{
+		files:    newResultCache[*hcl.File](),
+		includes: newResultCache[[]config.IncludeConfig](),
+		locals:   newResultCache[ResolvedLocals](),
+	}
+}
+
+// Returns the key of the config that `ctx` evaluates files for, with the feature flag values it evaluates them with
+func evaluationKey(ctx *config.ParsingContext, path string) string {
+	return fmt.Sprintf("%s|%s|%s%s", filepath.Clean(path), ctx.TerragruntOptions.TerragruntConfigPath, ctx.TerragruntOptions.OriginalTerragruntConfigPath, featureKey(ctx.TerragruntOptions))
+}
+
+// Reads and parses the config at `path`, or returns it from the cache. Bare `include` blocks are labeled
+// before caching, so decoding never has to update the shared file.
+func readConfigFile(ctx *config.ParsingContext, path string) (*hclparse.File, error) {
+	path = filepath.Clean(path)
+	cache := runOf(ctx).parseCache
+	hclFile, err := cache.files.get(ctx, path, func() (*hcl.File, error) {
+		file, err := hclparse.NewParser(ctx.ParserOptions...).ParseFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Ext(path) == ".json" {
+			return file.File, nil
+		}
+
+		updatedBytes, isUpdated, err := updateBareIncludeBlock(file.File, path)
+		if err != nil {
+			return nil, err
+		}
+		if isUpdated {
+			file, err = hclparse.NewParser(ctx.ParserOptions...).ParseFromBytes(updatedBytes, path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cache.normalized.Store(file.File, true)
+		return file.File, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Each caller gets its own parser, as terragrunt updates the parser of a file while decoding it
+	parser := hclparse.NewParser(ctx.ParserOptions...)
+	parser.AddFile(path, hclFile)
+	return &hclparse.File{Parser: parser, File: hclFile, ConfigPath: path}, nil
+}
+
+// Checks if the bare `include` blocks of a file were already labeled by `readConfigFile`
+func isNormalizedFile(ctx context.Context, file *hcl.File) bool {
+	_, ok := runOf(ctx).parseCache.normalized.Load(file)
+	return ok
+}
+
+// Returns a copy of locals that shares no slices or maps with them, so cached locals can't be changed by callers
+func cloneResolvedLocals(locals ResolvedLocals) ResolvedLocals {
+	cloneStrings := func(values []string) []string {
+		if values == nil {
+			return nil
+		}
+		return append([]string{}, values...)
+	}
+
+	locals.ApplyRequirements = cloneStrings(locals.ApplyRequirements)
+	locals.ExtraAtlantisDependencies = cloneStrings(locals.ExtraAtlantisDependencies)
+	locals.WhenModifiedExtra = cloneStrings(locals.WhenModifiedExtra)
+	locals.WhenModifiedExclude = cloneStrings(locals.WhenModifiedExclude)
+	locals.SidecarFiles = cloneStrings(locals.SidecarFiles)
+	locals.Cascade.Edges = cloneStrings(locals.Cascade.Edges)
+	if locals.MergeStrategy != nil {
+		mergeStrategy := map[string]string{}
+		for key, value := range locals.MergeStrategy {
+			mergeStrategy[key] = value
+		}
+		locals.MergeStrategy = mergeStrategy
+	}
+	return locals
+}
@@ -1,10 +1,11 @@
 package cmd
 
 import (
+	goerrors "errors"
+	"fmt"
 	"github.com/gruntwork-io/go-commons/errors"
 	"github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/config/hclparse"
-	"github.com/gruntwork-io/terragrunt/util"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclwrite"
@@ -73,9 +74,10 @@ func decodeHcl(
 		}
 	}()
 
-	// Check if we need to update the file to label any bare include blocks.
+	// Check if we need to update the file to label any bare include blocks. Files read with `readConfigFile`
+	// are labeled already.
 	// Excluding json because of https://github.com/transcend-io/terragrunt-atlantis-config/issues/244.
-	if filepath.Ext(filename) != ".json" {
+	if filepath.Ext(filename) != ".json" && !isNormalizedFile(ctx, file) {
 		updatedBytes, isUpdated, err := updateBareIncludeBlock(file, filename)
 		if err != nil {
 			return err
@@ -108,40 +110,50 @@ func decodeHcl(
 // the config.
 // For consistency, `include` in the call to `decodeHcl` is always assumed to be nil. Either it really is nil (parsing
 // the child config), or it shouldn't be used anyway (the parent config shouldn't have an include block).
+// The includes are decoded once per run for each config they are evaluated for, see `parseCache`.
 func decodeAsTerragruntInclude(
 	ctx *config.ParsingContext,
 	file *hcl.File,
 	filename string,
 ) ([]config.IncludeConfig, error) {
-	tgInc := terragruntIncludeMultiple{}
-	if err := decodeHcl(ctx, file, filename, &tgInc); err != nil {
+	includes, err := runOf(ctx).parseCache.includes.get(ctx, evaluationKey(ctx, filename), func() ([]config.IncludeConfig, error) {
+		tgInc := terragruntIncludeMultiple{}
+		if err := decodeHcl(ctx, file, filename, &tgInc); err != nil {
+			return nil, err
+		}
+		return tgInc.Include, nil
+	})
+	if err != nil {
 		return nil, err
 	}
-	return tgInc.Include, nil
+	return append([]config.IncludeConfig{}, includes...), nil
 }
 
-// Not all modules need an include statement, as they could define everything in one file without a parent
-// The key signifiers of a parent are:
+// Not all modules need an include statement, as they could define everything in one file without a parent.
+// Parents can be marked explicitly, see `detectParentConfig`. Otherwise, the key signifiers of a parent are:
 //   - no include statement
 //   - no terraform source defined
 //
 // If both of those are true, it is likely a parent module
 func parseModule(ctx *config.ParsingContext, path string) (isParent bool, includes []config.IncludeConfig, err error) {
-	configString, err := util.ReadFileAsString(path)
+	parsedFile, err := readConfigFile(ctx, path)
 	if err != nil {
 		return false, nil, err
 	}
+	file := parsedFile.File
 
-	parser := hclparse.NewParser()
-	file, err := parseHcl(parser, configString, path)
+	terragruntIncludeList, err := decodeAsTerragruntInclude(ctx, file, path)
 	if err != nil {
 		return false, nil, err
 	}
 
-	terragruntIncludeList, err := decodeAsTerragruntInclude(ctx, file, path)
+	explicitlyParent, err := detectParentConfig(ctx, file, path)
 	if err != nil {
 		return false, nil, err
 	}
+	if explicitlyParent != nil {
+		return *explicitlyParent, terragruntIncludeList, nil
+	}
 
 	// If the file has any `include` blocks it is not a parent
 	if len(terragruntIncludeList) > 0 {
@@ -160,3 +172,83 @@ func parseModule(ctx *config.ParsingContext, path string) (isParent bool, includ
 
 	return false, nil, nil
 }
+
+// Partially parses a terragrunt config, just like `config.PartialParseConfigFile`, but also supports include chains
+// more than one level deep, like a child that includes `_envcommon/vpc.hcl`, which in turn includes `root.hcl`.
+// Terragrunt itself refuses to parse those, so the config is parsed without its `include` blocks instead, and merged
+// over each of the configs it includes, in order.
+func partialParseConfigChain(ctx *config.ParsingContext, path string, chain []string) (*config.TerragruntConfig, error) {
+	file, err := readConfigFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	parsedConfig, err := config.TerragruntConfigFromPartialConfig(ctx, file, nil)
+	var tooManyLevelsErr config.TooManyLevelsOfInheritanceError
+	if err == nil || !goerrors.As(err, &tooManyLevelsErr) {
+		return parsedConfig, err
+	}
+
+	path = filepath.Clean(path)
+	for _, includedFrom := range chain {
+		if includedFrom == path {
+			return nil, &includeCycleError{chain: append(chain, path)}
+		}
+	}
+	chain = append(append([]string{}, chain...), path)
+
+	includes, err := decodeAsTerragruntInclude(ctx, file.File, path)
+	if err != nil {
+		return nil, err
+	}
+
+	ownFile, err := hclparse.NewParser(ctx.ParserOptions...).ParseFromString(string(removeIncludeBlocks(file.File)), path)
+	if err != nil {
+		return nil, err
+	}
+	ownConfig, err := config.PartialParseConfig(ctx, ownFile, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var mergedConfig *config.TerragruntConfig
+	for _, include := range includes {
+		includePath := include.Path
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(path), includePath)
+		}
+
+		includedConfig, err := partialParseConfigChain(ctx, includePath, chain)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s included from %s: %w", includePath, path, err)
+		}
+
+		if mergedConfig == nil {
+			mergedConfig = includedConfig
+		} else if err := mergedConfig.Merge(includedConfig, ctx.TerragruntOptions); err != nil {
+			return nil, err
+		}
+	}
+
+	if mergedConfig == nil {
+		return ownConfig, nil
+	}
+	if err := mergedConfig.Merge(ownConfig, ctx.TerragruntOptions); err != nil {
+		return nil, err
+	}
+	return mergedConfig, nil
+}
+
+// Returns the source of a config with all of its `include` blocks removed
+func removeIncludeBlocks(file *hcl.File) []byte {
+	hclFile, diags := hclwrite.ParseConfig(file.Bytes, file.Body.MissingItemRange().Filename, hcl.InitialPos)
+	if diags.HasErrors() {
+		return file.Bytes
+	}
+
+	for _, block := range hclFile.Body().Blocks() {
+		if block.Type() == "include" {
+			hclFile.Body().RemoveBlock(block)
+		}
+	}
+	return hclFile.Bytes()
+}
@@ -5,13 +5,17 @@ package cmd
 // parses the `locals` blocks and evaluates their contents.
 
 import (
+	"context"
 	"fmt"
 	"github.com/gruntwork-io/go-commons/errors"
 	"github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/config/hclparse"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
+	"math/big"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // ResolvedLocals are the parsed result of local values this module cares about
@@ -25,6 +29,12 @@ type ResolvedLocals struct {
 	// Extra dependencies that can be hardcoded in config
 	ExtraAtlantisDependencies []string
 
+	// Extra `when_modified` patterns, relative to the project directory
+	WhenModifiedExtra []string
+
+	// `when_modified` patterns to exclude, relative to the project directory
+	WhenModifiedExclude []string
+
 	// If set, a single module will have autoplan turned to this setting
 	AutoPlan *bool
 
@@ -34,6 +44,18 @@ type ResolvedLocals struct {
 	// Terraform version to use just for this project
 	TerraformVersion string
 
+	// Terraform distribution to use just for this project
+	TerraformDistribution string
+
+	// Cascading settings to use just for this project
+	Cascade CascadeLocals
+
+	// How locals are merged with the locals of parent configs, by local name
+	MergeStrategy map[string]string
+
+	// The absolute paths of the sidecar files these locals were read from
+	SidecarFiles []string
+
 	// If set to true, create Atlantis project
 	markedProject *bool
 }
@@ -75,6 +97,10 @@ func mergeResolvedLocals(parent ResolvedLocals, child ResolvedLocals) ResolvedLo
 		parent.TerraformVersion = child.TerraformVersion
 	}
 
+	if child.TerraformDistribution != "" {
+		parent.TerraformDistribution = child.TerraformDistribution
+	}
+
 	if child.AutoPlan != nil {
 		parent.AutoPlan = child.AutoPlan
 	}
@@ -87,51 +113,317 @@ func mergeResolvedLocals(parent ResolvedLocals, child ResolvedLocals) ResolvedLo
 		parent.markedProject = child.markedProject
 	}
 
-	if child.ApplyRequirements != nil || len(child.ApplyRequirements) > 0 {
-		parent.ApplyRequirements = child.ApplyRequirements
-	}
+	strategies := mergeMergeStrategies(parent.MergeStrategy, child.MergeStrategy)
+	parent.MergeStrategy = strategies
+	parent.SidecarFiles = append(parent.SidecarFiles, child.SidecarFiles...)
 
-	parent.ExtraAtlantisDependencies = append(parent.ExtraAtlantisDependencies, child.ExtraAtlantisDependencies...)
+	parent.ApplyRequirements = mergeListLocal(resolveMergeStrategy(strategies, "atlantis_apply_requirements"), parent.ApplyRequirements, child.ApplyRequirements)
+	parent.ExtraAtlantisDependencies = mergeListLocal(resolveMergeStrategy(strategies, "extra_atlantis_dependencies"), parent.ExtraAtlantisDependencies, child.ExtraAtlantisDependencies)
+	parent.WhenModifiedExtra = mergeListLocal(resolveMergeStrategy(strategies, "atlantis_when_modified_extra"), parent.WhenModifiedExtra, child.WhenModifiedExtra)
+	parent.WhenModifiedExclude = mergeListLocal(resolveMergeStrategy(strategies, "atlantis_when_modified_exclude"), parent.WhenModifiedExclude, child.WhenModifiedExclude)
+
+	if resolveMergeStrategy(strategies, "atlantis_cascade") == mergeStrategyReplace {
+		if child.Cascade.Enabled != nil || child.Cascade.Edges != nil || child.Cascade.Depth != nil {
+			parent.Cascade = child.Cascade
+		}
+	} else {
+		parent.Cascade = mergeCascadeLocals(parent.Cascade, child.Cascade)
+	}
 
 	return parent
 }
 
-// Parses a given file, returning a map of all it's `local` values
+// Parses a given file, returning a map of all it's `local` values, merged with the `local` values of
+// every config it includes, all the way up the include chain, and with the sidecar files next to it.
+// The locals of a module are resolved once per run for each config they are evaluated for, see `parseCache`.
 func parseLocals(ctx *config.ParsingContext, path string, includeFromChild *config.IncludeConfig) (ResolvedLocals, error) {
-	file, err := hclparse.NewParser(ctx.ParserOptions...).ParseFromFile(path)
+	// Sidecar files belong to the directory of the module being generated, not to the configs it includes
+	if includeFromChild != nil {
+		return parseLocalsInChain(ctx, path, includeFromChild, nil)
+	}
+
+	locals, err := runOf(ctx).parseCache.locals.get(ctx, evaluationKey(ctx, path), func() (ResolvedLocals, error) {
+		parentLocals, childLocals, err := parseLocalLayers(ctx, path, nil, nil)
+		if err != nil {
+			return ResolvedLocals{}, err
+		}
+		sidecarLocals, err := parseSidecarFiles(ctx, path)
+		if err != nil {
+			return ResolvedLocals{}, err
+		}
+
+		// Sidecar files are closer to the module than the configs it includes, but the module's own locals win
+		return mergeResolvedLocals(mergeResolvedLocals(parentLocals, sidecarLocals), childLocals), nil
+	})
 	if err != nil {
 		return ResolvedLocals{}, err
 	}
+	return cloneResolvedLocals(locals), nil
+}
+
+// Parses the locals of a single config in an include chain, where `chain` holds the configs that
+// (transitively) include this one
+func parseLocalsInChain(ctx *config.ParsingContext, path string, includeFromChild *config.IncludeConfig, chain []string) (ResolvedLocals, error) {
+	parentLocals, childLocals, err := parseLocalLayers(ctx, path, includeFromChild, chain)
+	if err != nil {
+		return ResolvedLocals{}, err
+	}
+	return mergeResolvedLocals(parentLocals, childLocals), nil
+}
+
+// Parses the locals of a single config in an include chain, returning the merged locals of the configs it
+// includes and its own locals separately
+func parseLocalLayers(ctx *config.ParsingContext, path string, includeFromChild *config.IncludeConfig, chain []string) (ResolvedLocals, ResolvedLocals, error) {
+	path = filepath.Clean(path)
+	for _, includedFrom := range chain {
+		if includedFrom == path {
+			return ResolvedLocals{}, ResolvedLocals{}, &includeCycleError{chain: append(chain, path)}
+		}
+	}
+	chain = append(append([]string{}, chain...), path)
+
+	file, err := readConfigFile(ctx, path)
+	if err != nil {
+		return ResolvedLocals{}, ResolvedLocals{}, err
+	}
+
+	// Terragrunt itself only supports a single level of includes, and refuses to decode an included config
+	// that has includes of its own. Those configs are decoded as if they were not included, so their own
+	// parents can be followed as well.
+	includes, err := decodeAsTerragruntInclude(ctx, file.File, path)
+	if err != nil {
+		return ResolvedLocals{}, ResolvedLocals{}, err
+	}
+	if len(includes) > 0 {
+		includeFromChild = nil
+	}
 
 	// Decode just the Base blocks. See the function docs for DecodeBaseBlocks for more info on what base blocks are.
 	baseBlocks, err := config.DecodeBaseBlocks(ctx, file, includeFromChild)
 	if err != nil {
-		return ResolvedLocals{}, err
+		return ResolvedLocals{}, ResolvedLocals{}, err
 	}
 
-	// Recurse on the parent to merge in the locals from that file
+	// Recurse on the parents to merge in the locals from those files. Parents are merged in the order they are
+	// included, so a later include overrides an earlier one, and the config itself overrides all of its parents.
 	mergedParentLocals := ResolvedLocals{}
 	if baseBlocks.TrackInclude != nil && includeFromChild == nil {
 		for _, includeConfig := range baseBlocks.TrackInclude.CurrentList {
-			parentLocals, _ := parseLocals(ctx, includeConfig.Path, &includeConfig)
+			includePath := includeConfig.Path
+			if !filepath.IsAbs(includePath) {
+				includePath = filepath.Join(filepath.Dir(path), includePath)
+			}
+
+			parentLocals, err := parseLocalsInChain(ctx, includePath, &includeConfig, chain)
+			if err != nil {
+				return ResolvedLocals{}, ResolvedLocals{}, fmt.Errorf("failed to parse locals of %s included from %s: %w", includePath, path, err)
+			}
 			mergedParentLocals = mergeResolvedLocals(mergedParentLocals, parentLocals)
 		}
 	}
-	childLocals, err := resolveLocals(*baseBlocks.Locals)
+	childLocals, err := resolveLocals(ctx, *baseBlocks.Locals, path)
 	if err != nil {
-		return ResolvedLocals{}, err
+		return ResolvedLocals{}, ResolvedLocals{}, err
 	}
-	return mergeResolvedLocals(mergedParentLocals, childLocals), nil
+	return mergedParentLocals, childLocals, nil
+}
+
+// The attributes of the nested `atlantis` local, along with the prefixed local each one stands for
+// and the type its value must have
+var atlantisLocalSchema = map[string]struct {
+	local string
+	ty    cty.Type
+}{
+	"workflow":               {"atlantis_workflow", cty.String},
+	"apply_requirements":     {"atlantis_apply_requirements", cty.List(cty.String)},
+	"terraform_version":      {"atlantis_terraform_version", cty.String},
+	"terraform_distribution": {"atlantis_terraform_distribution", cty.String},
+	"autoplan":               {"atlantis_autoplan", cty.Bool},
+	"skip":                   {"atlantis_skip", cty.Bool},
+	"extra_dependencies":     {"extra_atlantis_dependencies", cty.List(cty.String)},
+	"when_modified_extra":    {"atlantis_when_modified_extra", cty.List(cty.String)},
+	"when_modified_exclude":  {"atlantis_when_modified_exclude", cty.List(cty.String)},
+	"cascade":                {"atlantis_cascade", cascadeLocalType},
+	"merge_strategy":         {"atlantis_merge_strategy", cty.Map(cty.String)},
+	"project":                {"atlantis_project", cty.Bool},
+	"is_parent":              {"atlantis_is_parent", cty.Bool},
 }
 
-func resolveLocals(localsAsCty cty.Value) (ResolvedLocals, error) {
+// The type of the `atlantis_cascade` local, an object with optional `enabled`, `edges` and `depth` attributes
+var cascadeLocalType = cty.ObjectWithOptionalAttrs(map[string]cty.Type{
+	"enabled": cty.Bool,
+	"edges":   cty.List(cty.String),
+	"depth":   cty.Number,
+}, []string{"enabled", "edges", "depth"})
+
+// Checks that `value` has exactly the type `ty`, without the conversions the HCL type system would allow, like
+// `"false"` for a bool. Returns an empty string when it does, or else what is wrong with it. Unknown values are
+// left to the caller.
+func checkLocalType(ty cty.Type, value cty.Value) string {
+	if !value.IsWhollyKnown() {
+		return ""
+	}
+	if value.IsNull() {
+		return "must not be null"
+	}
+
+	valueType := value.Type()
+	switch {
+	case ty.IsPrimitiveType():
+		if !valueType.Equals(ty) {
+			return fmt.Sprintf("must be %s, got %s", typeNameWithArticle(ty), valueType.FriendlyName())
+		}
+	case ty.IsListType():
+		if !valueType.IsListType() && !valueType.IsTupleType() && !valueType.IsSetType() {
+			return fmt.Sprintf("must be %s, got %s", typeNameWithArticle(ty), valueType.FriendlyName())
+		}
+		position := 0
+		for it := value.ElementIterator(); it.Next(); position++ {
+			_, element := it.Element()
+			if element.IsNull() || !element.Type().Equals(ty.ElementType()) {
+				return fmt.Sprintf("must be %s, got %s at position %d", typeNameWithArticle(ty), element.Type().FriendlyName(), position)
+			}
+		}
+	case ty.IsMapType():
+		if !valueType.IsObjectType() && !valueType.IsMapType() {
+			return fmt.Sprintf("must be %s, got %s", typeNameWithArticle(ty), valueType.FriendlyName())
+		}
+		elements := value.AsValueMap()
+		for _, key := range sortedKeys(elements) {
+			if message := checkLocalType(ty.ElementType(), elements[key]); message != "" {
+				return fmt.Sprintf("value for %s %s", key, message)
+			}
+		}
+	case ty.IsObjectType():
+		if !valueType.IsObjectType() && !valueType.IsMapType() {
+			return fmt.Sprintf("must be %s, got %s", typeNameWithArticle(ty), valueType.FriendlyName())
+		}
+		attributes := value.AsValueMap()
+		for _, key := range sortedKeys(attributes) {
+			if !ty.HasAttribute(key) {
+				return fmt.Sprintf("has unknown attribute %s, must be one of: %s", key, strings.Join(sortedKeys(ty.AttributeTypes()), ", "))
+			}
+			if message := checkLocalType(ty.AttributeType(key), attributes[key]); message != "" {
+				return fmt.Sprintf("%s %s", key, message)
+			}
+		}
+	}
+	return ""
+}
+
+// Returns the name of `ty` for messages, like `a bool` or `an object`
+func typeNameWithArticle(ty cty.Type) string {
+	if ty.IsObjectType() {
+		return "an object"
+	}
+	return "a " + ty.FriendlyName()
+}
+
+// Checks that each of the locals read by this tool has exactly the type it should have, so a value of the wrong
+// type is reported rather than read
+func checkLocalTypes(rawLocals map[string]cty.Value, path string) error {
+	for _, key := range sortedKeys(atlantisLocalSchema) {
+		attribute := atlantisLocalSchema[key]
+		value, ok := rawLocals[attribute.local]
+		if !ok {
+			continue
+		}
+		if !value.IsWhollyKnown() {
+			return fmt.Errorf("%s: the value of %s is not known", path, attribute.local)
+		}
+
+		// Values in a list of extra dependencies that aren't strings are reported by their position
+		valueType := value.Type()
+		if attribute.local == "extra_atlantis_dependencies" && !value.IsNull() && (valueType.IsListType() || valueType.IsTupleType() || valueType.IsSetType()) {
+			continue
+		}
+		if message := checkLocalType(attribute.ty, value); message != "" {
+			return fmt.Errorf("%s: %s %s", path, attribute.local, message)
+		}
+	}
+	return nil
+}
+
+// Expands the nested `atlantis = { ... }` local into the prefixed locals it stands for, so both styles
+// can be read the same way. When a setting is given both ways, the prefixed local wins.
+func expandAtlantisLocal(ctx context.Context, rawLocals map[string]cty.Value, path string) (map[string]cty.Value, error) {
+	atlantisValue, ok := rawLocals["atlantis"]
+	if !ok {
+		return rawLocals, nil
+	}
+	if !atlantisValue.Type().IsObjectType() && !atlantisValue.Type().IsMapType() {
+		return nil, fmt.Errorf("%s: the atlantis local must be an object", path)
+	}
+
+	expanded := map[string]cty.Value{}
+	for key, val := range rawLocals {
+		expanded[key] = val
+	}
+
+	nestedLocals, err := atlantisAttributesToLocals(atlantisValue.AsValueMap(), path, "atlantis.")
+	if err != nil {
+		return nil, err
+	}
+
+	for key, attribute := range atlantisLocalSchema {
+		val, ok := nestedLocals[attribute.local]
+		if !ok {
+			continue
+		}
+		if _, ok := rawLocals[attribute.local]; ok {
+			// Locals are parsed more than once per module, so only warn the first time
+			warning := fmt.Sprintf("%s: both atlantis.%s and %s are set", path, key, attribute.local)
+			if _, warned := runOf(ctx).localConflicts.LoadOrStore(warning, true); !warned {
+				reportDiagnostic(ctx, ruleConflictingAtlantisLocal, path, nil, "both atlantis.%s and %s are set, using %s", key, attribute.local, attribute.local)
+			}
+			continue
+		}
+		expanded[attribute.local] = val
+	}
+
+	return expanded, nil
+}
+
+// Converts attributes following the schema of the nested `atlantis` local into the prefixed locals they
+// stand for, checking that each value has exactly the type of its local, see `checkLocalType`. `prefix` is how
+// the attributes are referred to in errors.
+func atlantisAttributesToLocals(attributes map[string]cty.Value, path string, prefix string) (map[string]cty.Value, error) {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	locals := map[string]cty.Value{}
+	for _, key := range keys {
+		attribute, ok := atlantisLocalSchema[key]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown attribute %s%s", path, prefix, key)
+		}
+
+		if message := checkLocalType(attribute.ty, attributes[key]); message != "" {
+			return nil, fmt.Errorf("%s: %s%s %s", path, prefix, key, message)
+		}
+		locals[attribute.local] = attributes[key]
+	}
+
+	return locals, nil
+}
+
+func resolveLocals(ctx context.Context, localsAsCty cty.Value, path string) (ResolvedLocals, error) {
 	resolved := ResolvedLocals{}
 
 	// Return an empty set of locals if no `locals` block was present
 	if localsAsCty == cty.NilVal {
 		return resolved, nil
 	}
-	rawLocals := localsAsCty.AsValueMap()
+	rawLocals, err := expandAtlantisLocal(ctx, localsAsCty.AsValueMap(), path)
+	if err != nil {
+		return resolved, err
+	}
+	if err := checkLocalTypes(rawLocals, path); err != nil {
+		return resolved, err
+	}
 
 	workflowValue, ok := rawLocals["atlantis_workflow"]
 	if ok {
@@ -143,6 +435,14 @@ func resolveLocals(localsAsCty cty.Value) (ResolvedLocals, error) {
 		resolved.TerraformVersion = versionValue.AsString()
 	}
 
+	distributionValue, ok := rawLocals["atlantis_terraform_distribution"]
+	if ok {
+		resolved.TerraformDistribution = distributionValue.AsString()
+		if err := validateDistribution(resolved.TerraformDistribution); err != nil {
+			return resolved, err
+		}
+	}
+
 	autoPlanValue, ok := rawLocals["atlantis_autoplan"]
 	if ok {
 		hasValue := autoPlanValue.True()
@@ -165,6 +465,44 @@ func resolveLocals(localsAsCty cty.Value) (ResolvedLocals, error) {
 		}
 	}
 
+	whenModifiedExtra, ok := rawLocals["atlantis_when_modified_extra"]
+	if ok {
+		it := whenModifiedExtra.ElementIterator()
+		for it.Next() {
+			_, val := it.Element()
+			resolved.WhenModifiedExtra = append(resolved.WhenModifiedExtra, val.AsString())
+		}
+	}
+
+	whenModifiedExclude, ok := rawLocals["atlantis_when_modified_exclude"]
+	if ok {
+		it := whenModifiedExclude.ElementIterator()
+		for it.Next() {
+			_, val := it.Element()
+			resolved.WhenModifiedExclude = append(resolved.WhenModifiedExclude, val.AsString())
+		}
+	}
+
+	cascadeValue, ok := rawLocals["atlantis_cascade"]
+	if ok {
+		cascade, err := resolveCascadeLocals(cascadeValue)
+		if err != nil {
+			return resolved, err
+		}
+		resolved.Cascade = cascade
+	}
+
+	mergeStrategyValue, ok := rawLocals["atlantis_merge_strategy"]
+	if ok {
+		resolved.MergeStrategy = map[string]string{}
+		for key, val := range mergeStrategyValue.AsValueMap() {
+			resolved.MergeStrategy[key] = val.AsString()
+		}
+		if err := validateMergeStrategies(resolved.MergeStrategy); err != nil {
+			return resolved, fmt.Errorf("atlantis_merge_strategy: %w", err)
+		}
+	}
+
 	markedProject, ok := rawLocals["atlantis_project"]
 	if ok {
 		hasValue := markedProject.True()
@@ -178,7 +516,7 @@ func resolveLocals(localsAsCty cty.Value) (ResolvedLocals, error) {
 			pos, val := it.Element()
 			if !val.Type().Equals(cty.String) {
 				posInt, _ := pos.AsBigFloat().Int64()
-				return resolved, fmt.Errorf("extra_atlantis_dependencies contains non-string value at position %d", posInt)
+				return resolved, &nonStringExtraDependencyError{position: posInt}
 			}
 
 			resolved.ExtraAtlantisDependencies = append(
@@ -190,3 +528,43 @@ func resolveLocals(localsAsCty cty.Value) (ResolvedLocals, error) {
 
 	return resolved, nil
 }
+
+// Parses the `atlantis_cascade` local, which must already be known to be of `cascadeLocalType`
+func resolveCascadeLocals(value cty.Value) (CascadeLocals, error) {
+	resolved := CascadeLocals{}
+	rawCascade := value.AsValueMap()
+
+	enabledValue, ok := rawCascade["enabled"]
+	if ok {
+		hasValue := enabledValue.True()
+		resolved.Enabled = &hasValue
+	}
+
+	edgesValue, ok := rawCascade["edges"]
+	if ok {
+		resolved.Edges = []string{}
+		it := edgesValue.ElementIterator()
+		for it.Next() {
+			_, val := it.Element()
+			resolved.Edges = append(resolved.Edges, val.AsString())
+		}
+		if err := validateCascadeEdges(resolved.Edges); err != nil {
+			return resolved, fmt.Errorf("atlantis_cascade: %w", err)
+		}
+	}
+
+	depthValue, ok := rawCascade["depth"]
+	if ok {
+		depth64, accuracy := depthValue.AsBigFloat().Int64()
+		if accuracy != big.Exact {
+			return resolved, fmt.Errorf("atlantis_cascade: depth must be a whole number, got %s", depthValue.AsBigFloat().String())
+		}
+		depth := int(depth64)
+		if err := validateCascadeDepth(depth); err != nil {
+			return resolved, fmt.Errorf("atlantis_cascade: %w", err)
+		}
+		resolved.Depth = &depth
+	}
+
+	return resolved, nil
+}
@@ -1,13 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io/fs"
+	"os"
 	"strings"
 
 	"github.com/gruntwork-io/terragrunt/util"
 	"github.com/hashicorp/terraform-config-inspect/tfconfig"
 )
 
+// The values Atlantis accepts for `terraform_distribution`
+const (
+	distributionTerraform = "terraform"
+	distributionOpenTofu  = "opentofu"
+)
+
 var localModuleSourcePrefixes = []string{
 	"./",
 	"../",
@@ -15,8 +25,34 @@ var localModuleSourcePrefixes = []string{
 	"..\\",
 }
 
-func parseTerraformLocalModuleSource(path string) ([]string, error) {
-	module, diags := tfconfig.LoadModule(path)
+// Ensures a distribution is one Atlantis knows about. An empty distribution leaves it up to Atlantis
+func validateDistribution(distribution string) error {
+	switch distribution {
+	case "", distributionTerraform, distributionOpenTofu:
+		return nil
+	}
+	return fmt.Errorf("unsupported terraform distribution %q, must be one of %q or %q", distribution, distributionTerraform, distributionOpenTofu)
+}
+
+// Returns the globs matching the configuration files of a distribution
+func terraformFileGlobs(distribution string) []string {
+	if distribution == distributionOpenTofu {
+		return []string{"*.tf*", "*.tofu*"}
+	}
+	return []string{"*.tf*"}
+}
+
+// Loads the module at `path`. For OpenTofu, `.tofu` files are read as well, taking precedence
+// over `.tf` files with the same name
+func loadTerraformModule(path string, distribution string) (*tfconfig.Module, tfconfig.Diagnostics) {
+	if distribution == distributionOpenTofu {
+		return tfconfig.LoadModuleFromFilesystem(tofuFs{}, path)
+	}
+	return tfconfig.LoadModule(path)
+}
+
+func parseTerraformLocalModuleSource(ctx context.Context, path string, distribution string) ([]string, error) {
+	module, diags := loadTerraformModule(path, distribution)
 	// modules, diags := parser.loadConfigDir(path)
 	if diags.HasErrors() {
 		return nil, errors.New(diags.Error())
@@ -26,15 +62,23 @@ func parseTerraformLocalModuleSource(path string) ([]string, error) {
 	for _, mc := range module.ModuleCalls {
 		if isLocalTerraformModuleSource(mc.Source) {
 			modulePath := util.JoinPath(path, mc.Source)
-			modulePathGlob := util.JoinPath(modulePath, "*.tf*")
+			if isExcludedPath(ctx, modulePath, true) {
+				continue
+			}
+			modulePathGlobs := []string{}
+			for _, glob := range terraformFileGlobs(distribution) {
+				modulePathGlobs = append(modulePathGlobs, util.JoinPath(modulePath, glob))
+			}
 
-			if _, exists := sourceMap[modulePathGlob]; exists {
+			if _, exists := sourceMap[modulePathGlobs[0]]; exists {
 				continue
 			}
-			sourceMap[modulePathGlob] = true
+			for _, modulePathGlob := range modulePathGlobs {
+				sourceMap[modulePathGlob] = true
+			}
 
 			// find local module source recursively
-			subSources, err := parseTerraformLocalModuleSource(modulePath)
+			subSources, err := parseTerraformLocalModuleSource(ctx, modulePath, distribution)
 			if err != nil {
 				return nil, err
 			}
@@ -62,3 +106,79 @@ func isLocalTerraformModuleSource(raw string) bool {
 
 	return false
 }
+
+// tofuFs presents `.tofu` and `.tofu.json` files to tfconfig, which only knows about terraform
+// file extensions, as `.tf` and `.tf.json` files. Like OpenTofu itself, a `.tf` file is hidden
+// when a `.tofu` file with the same name exists.
+type tofuFs struct{}
+
+func (tofuFs) Open(name string) (tfconfig.File, error) {
+	return os.Open(fromTofuFsName(name))
+}
+
+func (tofuFs) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(fromTofuFsName(name))
+}
+
+func (tofuFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+
+	infos := []os.FileInfo{}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		name := entry.Name()
+		switch {
+		case strings.HasSuffix(name, ".tofu"), strings.HasSuffix(name, ".tofu.json"):
+			infos = append(infos, tofuFileInfo{FileInfo: info, name: toTofuFsName(name)})
+		case strings.HasSuffix(name, ".tf") && names[strings.TrimSuffix(name, ".tf")+".tofu"]:
+			continue
+		case strings.HasSuffix(name, ".tf.json") && names[strings.TrimSuffix(name, ".tf.json")+".tofu.json"]:
+			continue
+		default:
+			infos = append(infos, info)
+		}
+	}
+
+	return infos, nil
+}
+
+// A file info with the name tfconfig sees
+type tofuFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i tofuFileInfo) Name() string {
+	return i.name
+}
+
+// Maps `main.tofu` to `main.tofu.tf` and `main.tofu.json` to `main.tofu.tf.json`
+func toTofuFsName(name string) string {
+	if strings.HasSuffix(name, ".json") {
+		return strings.TrimSuffix(name, ".json") + ".tf.json"
+	}
+	return name + ".tf"
+}
+
+// Reverses toTofuFsName
+func fromTofuFsName(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".tofu.tf.json"):
+		return strings.TrimSuffix(name, ".tf.json") + ".json"
+	case strings.HasSuffix(name, ".tofu.tf"):
+		return strings.TrimSuffix(name, ".tf")
+	}
+	return name
+}
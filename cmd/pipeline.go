@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// Collects the projects generated concurrently, indexed by directory so preserved projects are updated in place
+// without scanning every project
+type projectCollector struct {
+	mtx      sync.Mutex
+	projects []AtlantisProject
+
+	// The indexes into `projects` of the projects in each directory, in order
+	byDir map[string][]int
+}
+
+func newProjectCollector(projects []AtlantisProject) *projectCollector {
+	collector := &projectCollector{byDir: map[string][]int{}}
+	for _, project := range projects {
+		collector.append(project)
+	}
+	return collector
+}
+
+// Adds a project, which must be called with the lock held
+func (c *projectCollector) append(project AtlantisProject) {
+	c.byDir[project.Dir] = append(c.byDir[project.Dir], len(c.projects))
+	c.projects = append(c.projects, project)
+}
+
+// Adds a project, or replaces the first project that is the same, see `isSameProject`.
+// Returns true if an existing project was replaced.
+func (c *projectCollector) upsert(project AtlantisProject) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, i := range c.byDir[project.Dir] {
+		if isSameProject(c.projects[i], project) {
+			c.projects[i] = project
+			return true
+		}
+	}
+	c.append(project)
+	return false
+}
+
+// Adds a project, even if there already is the same one
+func (c *projectCollector) add(project AtlantisProject) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.append(project)
+}
+
+// Evaluates configs concurrently, with at most `--num-executors` of them being evaluated at once. The first error
+// cancels the context of the units still running, and units that haven't started yet are skipped.
+type unitScheduler struct {
+	group *errgroup.Group
+	ctx   context.Context
+	units *unitTracker
+}
+
+func newUnitScheduler(ctx context.Context) *unitScheduler {
+	group, groupCtx := errgroup.WithContext(ctx)
+	if numExecutors > 0 {
+		group.SetLimit(int(numExecutors))
+	}
+	return &unitScheduler{group: group, ctx: groupCtx, units: newUnitTracker()}
+}
+
+// Evaluates the config at `path` once an executor is free, unless the pipeline was cancelled in the meantime,
+// and stores the project it creates
+func (s *unitScheduler) schedule(path string, create func(ctx context.Context) (*AtlantisProject, error), store func(project AtlantisProject)) {
+	s.group.Go(func() error {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+
+		s.units.start(path)
+		project, finished, err := runUnit(s.ctx, path, create)
+		// A unit given up on after `--unit-timeout` keeps its executor until it returns, so there are never more
+		// than `--num-executors` configs being evaluated. Once the pipeline is done, nothing else is scheduled.
+		defer func() {
+			select {
+			case <-finished:
+			case <-s.ctx.Done():
+			}
+		}()
+		// Units cut short stay tracked, so they can be reported
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		s.units.finish(path)
+
+		if err != nil {
+			return handleProjectError(s.ctx, path, err)
+		}
+		// if project and err are nil then skip this project
+		if project != nil {
+			store(*project)
+		}
+		return nil
+	})
+}
+
+// Waits for the units scheduled, returning the first error
+func (s *unitScheduler) wait() error {
+	return s.group.Wait()
+}
+
+// Generates the projects of every working dir in a single pipeline, see `unitScheduler`. When `ctx` is done first,
+// the units still running are reported.
+func generateProjects(ctx context.Context, workingDirs []string, projectHclDirs []string, projectHclDirMap map[string][]string, collector *projectCollector) error {
+	pipelineCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	scheduler := newUnitScheduler(pipelineCtx)
+	groupCtx := scheduler.ctx
+	schedule := scheduler.schedule
+
+	// Stops scheduling units when a working dir can't be read, and waits for the units already running
+	abort := func(err error) error {
+		cancel()
+		_ = scheduler.wait()
+		return err
+	}
+
+	for _, workingDir := range workingDirs {
+		if groupCtx.Err() != nil {
+			break
+		}
+
+		terragruntFiles, err := getAllTerragruntFiles(ctx, workingDir)
+		if err != nil {
+			return abort(err)
+		}
+		if workingDir == gitRoot {
+			terragruntFiles = append(terragruntFiles, sortedKeys(runOf(ctx).parentConfigFiles)...)
+		}
+
+		if len(projectHclDirs) == 0 || createHclProjectChilds || (createHclProjectExternalChilds && workingDir == gitRoot) {
+			for _, terragruntPath := range terragruntFiles {
+				terragruntPath := terragruntPath // https://golang.org/doc/faq#closures_and_goroutines
+
+				// don't create atlantis projects already covered by project hcl file projects
+				if createHclProjectExternalChilds && workingDir == gitRoot && isInAnyDir(terragruntPath, projectHclDirs) {
+					continue
+				}
+
+				schedule(terragruntPath, func(ctx context.Context) (*AtlantisProject, error) {
+					return createProject(ctx, terragruntPath)
+				}, func(project AtlantisProject) {
+					reportEnvVarsRead(groupCtx, terragruntPath, []string{terragruntPath})
+
+					// When preserving existing projects, we should update existing blocks instead of creating a
+					// duplicate, when generating something which already has representation
+					if preserveProjects && collector.upsert(project) {
+						log.Info("Updated project for ", terragruntPath)
+						return
+					}
+					if !preserveProjects {
+						collector.add(project)
+					}
+					log.Info("Created project for ", terragruntPath)
+				})
+			}
+		}
+
+		if len(projectHclDirs) > 0 && workingDir != gitRoot {
+			workingDir := workingDir
+			projectHcl := lookupProjectHcl(projectHclDirMap, workingDir)
+			schedule(filepath.Join(workingDir, projectHcl), func(ctx context.Context) (*AtlantisProject, error) {
+				return createHclProject(ctx, terragruntFiles, workingDir, projectHcl)
+			}, func(project AtlantisProject) {
+				reportEnvVarsRead(groupCtx, filepath.Join(workingDir, projectHcl), append([]string{workingDir}, terragruntFiles...))
+				collector.add(project)
+				log.Info("Created "+projectHcl+" project for ", workingDir)
+			})
+		}
+	}
+
+	err := scheduler.wait()
+	if ctx.Err() != nil {
+		return reportInterruptedUnits(ctx, scheduler.units.list())
+	}
+	return err
+}
+
+// Checks if `path` is in any of `dirs`
+func isInAnyDir(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -24,6 +25,11 @@ func Execute(version string) {
 	VERSION = version
 
 	if err := rootCmd.Execute(); err != nil {
+		// Partial failures exit with a distinct status, as some output was still generated
+		var partialFailure *partialFailureError
+		if errors.As(err, &partialFailure) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// Represents a rules file, setting project attributes by the path of the project
+type ProjectRulesConfig struct {
+	// The rules, evaluated in order
+	Rules []ProjectRule `json:"rules"`
+}
+
+// Sets project attributes for every project whose dir matches a glob
+type ProjectRule struct {
+	// Glob matched against the project dir relative to the root, like `prod/**`
+	Match string `json:"match"`
+
+	// The workflow of matching projects
+	Workflow string `json:"workflow,omitempty"`
+
+	// The apply requirements of matching projects
+	ApplyRequirements *[]string `json:"apply_requirements,omitempty"`
+
+	// If autoplan should be enabled for matching projects
+	Autoplan *bool `json:"autoplan,omitempty"`
+
+	// The terraform version of matching projects
+	TerraformVersion string `json:"terraform_version,omitempty"`
+
+	// The terraform distribution of matching projects
+	TerraformDistribution string `json:"terraform_distribution,omitempty"`
+}
+
+// Reads the rules file at `path`, returning no rules if `path` is empty
+func readProjectRules(path string) ([]ProjectRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rulesConfig := ProjectRulesConfig{}
+	if err := yaml.Unmarshal(bytes, &rulesConfig); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i, rule := range rulesConfig.Rules {
+		if rule.Match == "" {
+			return nil, fmt.Errorf("%s: rule %d has no match pattern", path, i+1)
+		}
+		if _, err := matchPathGlob(rule.Match, ""); err != nil {
+			return nil, fmt.Errorf("%s: invalid match pattern %q in rule %d: %w", path, rule.Match, i+1, err)
+		}
+		if err := validateDistribution(rule.TerraformDistribution); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", path, i+1, err)
+		}
+	}
+
+	return rulesConfig.Rules, nil
+}
+
+// Combines every rule of the run of `ctx` matching the project dir `dir` into a single rule. Rules are applied in
+// order, so a later rule overrides the attributes set by earlier ones
+func resolveProjectRules(ctx context.Context, dir string) ProjectRule {
+	resolved := ProjectRule{}
+	for _, rule := range runOf(ctx).rules {
+		// Patterns are validated when the rules are read
+		if matched, _ := matchPathGlob(rule.Match, dir); !matched {
+			continue
+		}
+
+		if rule.Workflow != "" {
+			resolved.Workflow = rule.Workflow
+		}
+		if rule.ApplyRequirements != nil {
+			resolved.ApplyRequirements = rule.ApplyRequirements
+		}
+		if rule.Autoplan != nil {
+			resolved.Autoplan = rule.Autoplan
+		}
+		if rule.TerraformVersion != "" {
+			resolved.TerraformVersion = rule.TerraformVersion
+		}
+		if rule.TerraformDistribution != "" {
+			resolved.TerraformDistribution = rule.TerraformDistribution
+		}
+	}
+	return resolved
+}
+
+// Checks if the slash separated path `name` matches `pattern`, where `**` matches any number of
+// directories and every other segment follows the syntax of `path.Match`
+func matchPathGlob(pattern string, name string) (bool, error) {
+	patternSegments := strings.Split(path.Clean(pattern), "/")
+	for _, segment := range patternSegments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return false, err
+		}
+	}
+
+	nameSegments := []string{}
+	if cleaned := path.Clean(name); cleaned != "." {
+		nameSegments = strings.Split(cleaned, "/")
+	}
+	return matchPathSegments(patternSegments, nameSegments), nil
+}
+
+func matchPathSegments(pattern []string, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	if pattern[0] == "**" {
+		if matchPathSegments(pattern[1:], name) {
+			return true
+		}
+		return len(name) > 0 && matchPathSegments(pattern, name[1:])
+	}
+
+	if len(name) == 0 {
+		return false
+	}
+
+	matched, _ := path.Match(pattern[0], name[0])
+	return matched && matchPathSegments(pattern[1:], name[1:])
+}
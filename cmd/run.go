@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// The caches, reports and state of a single run of `generate` or `lint`. A run is carried by the context its configs
+// are evaluated in, so each unit reads and writes the run it was started in. That matters as a unit that is given up
+// on keeps running in the background, see `runUnit`, and must not see the state of a later run or write into it.
+type generationRun struct {
+	parseCache *parseCache
+
+	// The direct dependencies of each config, and the evaluations of them in progress
+	dependencies *GetDependenciesCache
+	requests     *singleflight.Group
+
+	diagnostics *diagnosticsCollector
+	failures    *failureCollector
+	envReads    *envReadTracker
+
+	// The sandboxed calls that have already been reported, so each distinct call is only logged once per config
+	sandboxedCalls *sync.Map
+
+	// The conflicts between nested and prefixed locals that have already been reported, as locals are parsed
+	// more than once per module
+	localConflicts *sync.Map
+
+	// The configs still being evaluated, including the ones that were given up on
+	evaluations sync.WaitGroup
+
+	// The environment `get_env` sees, see `loadEnv`, and the values given to each feature flag with `--feature`
+	env              map[string]string
+	featureOverrides map[string][]string
+
+	// If set, the side-effecting terragrunt functions are replaced with stubs, see `newParsingContext`
+	sandbox bool
+
+	// The ignore files and the index of the root, and the rules read from the `--rules-file` flag
+	ignoreRules *ignoreRulesCache
+	index       *repositoryIndex
+	rules       []ProjectRule
+
+	// The absolute paths of the configs included by another config, filled in when `--parent-when-included` is set
+	includedConfigs map[string]bool
+
+	// The absolute paths of the included parents that aren't terragrunt configs themselves, like root.hcl or
+	// _envcommon files, filled in when `--create-parent-project` is set
+	parentConfigFiles map[string]bool
+}
+
+func newGenerationRun() *generationRun {
+	return &generationRun{
+		parseCache:     newParseCache(),
+		dependencies:   newGetDependenciesCache(),
+		requests:       &singleflight.Group{},
+		diagnostics:    &diagnosticsCollector{},
+		failures:       newFailureCollector(),
+		envReads:       newEnvReadTracker(),
+		sandboxedCalls: &sync.Map{},
+		localConflicts: &sync.Map{},
+		env:            map[string]string{},
+		ignoreRules:    newIgnoreRulesCache(),
+	}
+}
+
+type generationRunKey struct{}
+
+// The run started last. Code that belongs to a run gets it from its context instead, see `runOf`.
+var latestRun = newGenerationRun()
+
+// Starts a new run, returning a context that carries it
+func startRun(ctx context.Context) context.Context {
+	run := newGenerationRun()
+	latestRun = run
+	return context.WithValue(ctx, generationRunKey{}, run)
+}
+
+// Returns the run that `ctx` belongs to, or the run started last when it doesn't belong to any
+func runOf(ctx context.Context) *generationRun {
+	if run, ok := ctx.Value(generationRunKey{}).(*generationRun); ok {
+		return run
+	}
+	return latestRun
+}
+
+// Reads the state the run of `ctx` shares between its units from the flags: the environment, the feature flag
+// overrides and the index of the root. With `sandboxed`, configs are evaluated in sandbox mode.
+func loadRunState(ctx context.Context, sandboxed bool) error {
+	run := runOf(ctx)
+	run.sandbox = sandboxed
+
+	var err error
+	run.env, err = loadEnv()
+	if err != nil {
+		return err
+	}
+	run.featureOverrides, err = parseFeatureOverrides(featureValues)
+	if err != nil {
+		return err
+	}
+	run.index, err = buildRepositoryIndex(ctx, gitRoot)
+	return err
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/options"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// The terragrunt functions that run commands, call cloud APIs or decrypt secrets, along with the fixed value
+// each one returns in sandbox mode. Functions without a fixed value return a placeholder derived from their arguments.
+//
+// `get_working_dir` is sandboxed as terragrunt evaluates it by parsing the whole config again with its own functions,
+// which would run the real versions of the others. Without a fixed value, it returns the directory of the config.
+var sandboxedFunctions = map[string]string{
+	"run_cmd":                         "",
+	"get_working_dir":                 "",
+	"sops_decrypt_file":               "{}",
+	"get_aws_account_id":              "000000000000",
+	"get_aws_account_alias":           "sandbox",
+	"get_aws_caller_identity_arn":     "arn:aws:iam::000000000000:user/sandbox",
+	"get_aws_caller_identity_user_id": "SANDBOX",
+}
+
+// Checks that every `--sandbox-value` is for a sandboxed function
+func validateSandboxValues(values map[string]string) error {
+	for name := range values {
+		if _, ok := sandboxedFunctions[name]; !ok {
+			return fmt.Errorf("unknown sandboxed function %q, must be one of: %s", name, strings.Join(sortedKeys(sandboxedFunctions), ", "))
+		}
+	}
+	return nil
+}
+
+// Creates the context configs are parsed in. Calls to `get_env` are recorded, and in sandbox mode the side-effecting
+// terragrunt functions are replaced with stubs. Both also apply to every config evaluated from the context, like
+// included configs and `read_terragrunt_config`.
+func newParsingContext(ctx context.Context, opts *options.TerragruntOptions) *config.ParsingContext {
+	parsingContext := config.NewParsingContext(ctx, opts)
+	parsingContext.PredefinedFunctions = map[string]function.Function{}
+	if runOf(ctx).sandbox {
+		parsingContext.PredefinedFunctions = sandboxFunctions(ctx, opts.TerragruntConfigPath)
+	}
+	parsingContext.PredefinedFunctions["get_env"] = envFunction(parsingContext, opts.TerragruntConfigPath)
+	return parsingContext
+}
+
+// Returns the stubs of the sandboxed functions for evaluating the config at `path` in the run of `ctx`
+func sandboxFunctions(ctx context.Context, path string) map[string]function.Function {
+	functions := map[string]function.Function{}
+	for name := range sandboxedFunctions {
+		functions[name] = sandboxFunction(ctx, name, path)
+	}
+	return functions
+}
+
+// Returns a stub of the function `name`, which returns the value of `--sandbox-value`, the fixed value of the
+// function, or else a placeholder that is the same for the same arguments. Configs are evaluated more than once
+// per run, so each distinct call of a config is only reported once, and repeated calls are logged at debug level.
+func sandboxFunction(ctx context.Context, name string, path string) function.Function {
+	run := runOf(ctx)
+	return function.New(&function.Spec{
+		VarParam: &function.Parameter{Type: cty.String},
+		Type:     function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			stringArgs := []string{}
+			for _, arg := range args {
+				if arg.IsNull() || !arg.IsKnown() {
+					return cty.UnknownVal(cty.String), nil
+				}
+				stringArgs = append(stringArgs, arg.AsString())
+			}
+
+			value, ok := sandboxValues[name]
+			if !ok {
+				value = sandboxedFunctions[name]
+			}
+			if value == "" && name == "get_working_dir" {
+				value = filepath.Dir(path)
+			}
+			if value == "" {
+				value = sandboxPlaceholder(name, stringArgs)
+			}
+
+			call := fmt.Sprintf("%s(%s)", name, strings.Join(quoteAll(stringArgs), ", "))
+			if _, logged := run.sandboxedCalls.LoadOrStore(path+"|"+call, true); !logged {
+				reportDiagnostic(ctx, ruleSandboxedCall, path, nil, "sandboxed %s, returning %q", call, value)
+			} else {
+				log.Debugf("Sandboxed %s while evaluating %s, returning %q", call, path, value)
+			}
+			return cty.StringVal(value), nil
+		},
+	})
+}
+
+// Returns the placeholder a sandboxed function returns for `args`, like `run_cmd-1f2e3d4c`
+func sandboxPlaceholder(name string, args []string) string {
+	hash := sha256.Sum256([]byte(strings.Join(args, "\x00")))
+	return name + "-" + hex.EncodeToString(hash[:4])
+}
+
+// Quotes each of `values`
+func quoteAll(values []string) []string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, fmt.Sprintf("%q", value))
+	}
+	return quoted
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Finds the sidecar files that apply to the config at `path`, from the git root down to the config's directory
+func findSidecarFiles(ctx context.Context, path string) []string {
+	if sidecarFilename == "" {
+		return nil
+	}
+
+	root := filepath.Clean(gitRoot)
+	sidecars := []string{}
+	dir := filepath.Dir(path)
+	for {
+		sidecar := filepath.Join(dir, sidecarFilename)
+		if indexedFileExists(ctx, sidecar) {
+			sidecars = append([]string{sidecar}, sidecars...)
+		}
+
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir || !strings.HasPrefix(parent, root) {
+			return sidecars
+		}
+		dir = parent
+	}
+}
+
+// Parses a sidecar file, whose top level attributes follow the schema of the nested `atlantis` local
+func parseSidecarFile(ctx *config.ParsingContext, path string) (ResolvedLocals, error) {
+	file, err := readConfigFile(ctx, path)
+	if err != nil {
+		return ResolvedLocals{}, err
+	}
+
+	attributes, diags := file.Body.JustAttributes()
+	if diags.HasErrors() {
+		return ResolvedLocals{}, diags
+	}
+
+	evalContext, err := createTerragruntEvalContext(ctx, path)
+	if err != nil {
+		return ResolvedLocals{}, err
+	}
+
+	values := map[string]cty.Value{}
+	for name, attribute := range attributes {
+		value, diags := attribute.Expr.Value(evalContext)
+		if diags.HasErrors() {
+			return ResolvedLocals{}, diags
+		}
+		values[name] = value
+	}
+
+	locals, err := atlantisAttributesToLocals(values, path, "")
+	if err != nil {
+		return ResolvedLocals{}, err
+	}
+
+	return resolveLocals(ctx, cty.ObjectVal(locals), path)
+}
+
+// Parses and merges the sidecar files that apply to the config at `path`. Sidecars closer to the config take
+// precedence over the ones in its parent directories.
+func parseSidecarFiles(ctx *config.ParsingContext, path string) (ResolvedLocals, error) {
+	locals := ResolvedLocals{}
+	for _, sidecar := range findSidecarFiles(ctx, path) {
+		sidecarLocals, err := parseSidecarFile(ctx, sidecar)
+		if err != nil {
+			return ResolvedLocals{}, err
+		}
+		sidecarLocals.SidecarFiles = []string{sidecar}
+
+		locals = mergeResolvedLocals(locals, sidecarLocals)
+	}
+
+	return locals, nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The root with all symlinks resolved, which terragrunt functions can return paths under instead of the root
+var realGitRoot string
+
+// Resolves the symlinks in the root, so that paths under the real root can be mapped back into it
+func resolveRealGitRoot() {
+	realGitRoot = gitRoot
+	if realRoot, err := filepath.EvalSymlinks(gitRoot); err == nil {
+		realGitRoot = filepath.Clean(realRoot) + string(filepath.Separator)
+	}
+}
+
+// Checks if the absolute `path` is `dir` or below it
+func isPathIn(path string, dir string) bool {
+	dir = filepath.Clean(dir)
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// Maps a path under the real root to the same path under the root as it was given, leaving other paths as is.
+// Projects and `when_modified` patterns are always relative to the root as given, even when it is a symlink.
+func toLinkRoot(path string) string {
+	if realGitRoot == "" || realGitRoot == gitRoot || !strings.HasPrefix(path, realGitRoot) {
+		return path
+	}
+	return gitRoot + strings.TrimPrefix(path, realGitRoot)
+}
+
+// With `--follow-symlinks`, returns the path of the file the absolute `path` really is below the root, so
+// `when_modified` names the file git sees changing rather than a path through a symlink. Globs are resolved
+// by their longest existing prefix. Paths that resolve to outside of the root are returned as is.
+func resolveRealPath(path string) string {
+	if !followSymlinks {
+		return path
+	}
+
+	existing := path
+	remainder := ""
+	for {
+		if realPath, err := filepath.EvalSymlinks(existing); err == nil {
+			resolved := toLinkRoot(filepath.Join(realPath, remainder))
+			if !isPathIn(resolved, gitRoot) {
+				return path
+			}
+			return resolved
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return path
+		}
+		remainder = filepath.Join(filepath.Base(existing), remainder)
+		existing = parent
+	}
+}
+
+// Returns the absolute path that a dependency of the config at `sourcePath` is matched by in `when_modified`
+func dependencyAbsolutePath(dependencyPath string, sourcePath string) string {
+	absolutePath := toLinkRoot(dependencyPath)
+	if !filepath.IsAbs(absolutePath) {
+		absolutePath = makePathAbsolute(dependencyPath, sourcePath)
+	}
+	return resolveRealPath(absolutePath)
+}
+
+// Returns the real directory of a config found through a symlinked directory, or an empty string if the
+// config isn't behind a symlink. Changes to the config show up in git under the real directory.
+func realConfigDir(sourcePath string) string {
+	dir := filepath.Dir(sourcePath)
+	realDir := resolveRealPath(dir)
+	if realDir == dir {
+		return ""
+	}
+	return realDir
+}
+
+// Checks if a directory entry is a symlink to a directory
+func isSymlinkToDir(path string, entry os.DirEntry) bool {
+	if entry.Type()&os.ModeSymlink == 0 {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
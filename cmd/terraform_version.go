@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	log "github.com/sirupsen/logrus"
+)
+
+// Files used by version managers to pin the version of a directory tree, by distribution
+var terraformVersionPinFiles = map[string][]string{
+	distributionTerraform: {".terraform-version", ".tool-versions"},
+	distributionOpenTofu:  {".opentofu-version", ".tool-versions"},
+}
+
+// The names of the plugins in asdf's `.tool-versions` file, by distribution
+var toolVersionsNames = map[string]string{
+	distributionTerraform: "terraform",
+	distributionOpenTofu:  "opentofu",
+}
+
+// A `required_version` constraint, along with the module directory that declared it
+type requiredCoreConstraint struct {
+	dir        string
+	constraint string
+}
+
+// Detects the terraform version of a module at `sourceDir`.
+//
+// The nearest version pin file between `sourceDir` and the git root wins. If there is none, the
+// `required_version` constraints of `moduleDirs` (and any local modules they call) are used, as
+// long as they pin a single exact version.
+//
+// Returns the detected version, or an empty string if none could be found, and the absolute
+// path of the pin file the version came from, if any.
+func detectTerraformVersion(ctx context.Context, sourceDir string, moduleDirs []string, distribution string) (string, string) {
+	if distribution == "" {
+		distribution = distributionTerraform
+	}
+
+	constraints := []requiredCoreConstraint{}
+	seen := map[string]bool{}
+	for _, moduleDir := range moduleDirs {
+		constraints = append(constraints, collectRequiredCore(moduleDir, distribution, seen)...)
+	}
+
+	pinnedVersion, pinFile := findTerraformVersionPin(sourceDir, distribution)
+	if pinnedVersion != "" {
+		for _, c := range constraints {
+			if !versionSatisfies(pinnedVersion, c.constraint) {
+				reportDiagnostic(ctx, ruleTerraformVersionConflict, pinFile, nil, "Terraform version %s pinned in %s does not satisfy required_version \"%s\" in %s", pinnedVersion, pinFile, c.constraint, c.dir)
+			}
+		}
+		return pinnedVersion, pinFile
+	}
+
+	return resolveRequiredCore(ctx, constraints), ""
+}
+
+// Walks from `dir` up to the git root, returning the version in the first pin file found. A pin file whose value is
+// not a concrete version (like `latest` or `min-required`) still stops the walk, as it is the one version managers
+// use, and no version is returned so `required_version` is used instead.
+func findTerraformVersionPin(dir string, distribution string) (string, string) {
+	root := filepath.Clean(gitRoot)
+	for {
+		for _, pinFile := range terraformVersionPinFiles[distribution] {
+			path := filepath.Join(dir, pinFile)
+			pinnedValue, err := readTerraformVersionPin(path, toolVersionsNames[distribution])
+			if err != nil || pinnedValue == "" {
+				continue
+			}
+			if _, err := version.NewVersion(pinnedValue); err != nil {
+				log.Debugf("Ignoring non-version value %q in %s", pinnedValue, path)
+				return "", ""
+			}
+			return pinnedValue, path
+		}
+
+		parent := filepath.Dir(dir)
+		if dir == root || parent == dir || !strings.HasPrefix(parent, root) {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// Reads the version out of a `.terraform-version` style file, or the `toolName` entry of a `.tool-versions` file.
+// Returns an empty string when the file has no entry for the tool.
+func readTerraformVersionPin(path string, toolName string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	isToolVersions := filepath.Base(path) == ".tool-versions"
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+
+		candidate := line
+		if isToolVersions {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || fields[0] != toolName {
+				continue
+			}
+			candidate = fields[1]
+		}
+
+		return strings.TrimPrefix(candidate, "v"), nil
+	}
+
+	return "", scanner.Err()
+}
+
+// Collects the `required_version` constraints of the module at `dir` and all local modules it calls
+func collectRequiredCore(dir string, distribution string, seen map[string]bool) []requiredCoreConstraint {
+	dir = filepath.Clean(dir)
+	if seen[dir] {
+		return nil
+	}
+	seen[dir] = true
+
+	module, diags := loadTerraformModule(dir, distribution)
+	if diags.HasErrors() {
+		return nil
+	}
+
+	constraints := []requiredCoreConstraint{}
+	for _, constraint := range module.RequiredCore {
+		constraints = append(constraints, requiredCoreConstraint{dir: dir, constraint: constraint})
+	}
+
+	for _, mc := range module.ModuleCalls {
+		if isLocalTerraformModuleSource(mc.Source) {
+			constraints = append(constraints, collectRequiredCore(filepath.Join(dir, mc.Source), distribution, seen)...)
+		}
+	}
+
+	return constraints
+}
+
+// Finds the single exact version pinned by a set of `required_version` constraints.
+// Conflicting pins, pins that do not satisfy the other constraints, and ranges that don't overlap are reported.
+func resolveRequiredCore(ctx context.Context, constraints []requiredCoreConstraint) string {
+	var pinned *requiredCoreConstraint
+	pinnedVersion := ""
+	for i, c := range constraints {
+		if exact := exactVersion(c.constraint); exact != "" {
+			pinned = &constraints[i]
+			pinnedVersion = exact
+			break
+		}
+	}
+
+	if pinned == nil {
+		reportUnsatisfiableConstraints(ctx, constraints)
+		return ""
+	}
+
+	for _, c := range constraints {
+		if !versionSatisfies(pinnedVersion, c.constraint) {
+			reportDiagnostic(ctx, ruleTerraformVersionConflict, c.dir, nil, "Conflicting required_version constraints: \"%s\" in %s and \"%s\" in %s", pinned.constraint, pinned.dir, c.constraint, c.dir)
+		}
+	}
+
+	return pinnedVersion
+}
+
+// One end of the range of versions a constraint allows, along with the constraint it comes from
+type versionBound struct {
+	version    *version.Version
+	inclusive  bool
+	constraint requiredCoreConstraint
+}
+
+// Reports constraints that no version can satisfy together, like `>= 1.6` in one module and `< 1.5` in another.
+// The highest lower bound is compared to the lowest upper bound, so `!=` constraints are not taken into account.
+func reportUnsatisfiableConstraints(ctx context.Context, constraints []requiredCoreConstraint) {
+	var lower, upper *versionBound
+	for _, c := range constraints {
+		for _, part := range strings.Split(c.constraint, ",") {
+			partLower, partUpper := constraintBounds(part)
+			if partLower != nil && (lower == nil || isHigherLowerBound(partLower, lower)) {
+				partLower.constraint = c
+				lower = partLower
+			}
+			if partUpper != nil && (upper == nil || isLowerUpperBound(partUpper, upper)) {
+				partUpper.constraint = c
+				upper = partUpper
+			}
+		}
+	}
+
+	if lower == nil || upper == nil {
+		return
+	}
+	comparison := lower.version.Compare(upper.version)
+	if comparison < 0 || (comparison == 0 && lower.inclusive && upper.inclusive) {
+		return
+	}
+
+	reportDiagnostic(ctx, ruleTerraformVersionConflict, upper.constraint.dir, nil, "Conflicting required_version constraints: \"%s\" in %s and \"%s\" in %s", lower.constraint.constraint, lower.constraint.dir, upper.constraint.constraint, upper.constraint.dir)
+}
+
+// Returns the lower and upper bound of a single constraint, like `>= 1.6` or `~> 1.5.0`. Either is nil when the
+// constraint doesn't bound that end, or can't be parsed.
+func constraintBounds(constraint string) (*versionBound, *versionBound) {
+	if _, err := version.NewConstraint(constraint); err != nil {
+		return nil, nil
+	}
+
+	raw := strings.TrimSpace(constraint)
+	operator := ""
+	for _, candidate := range []string{"~>", ">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(raw, candidate) {
+			operator = candidate
+			break
+		}
+	}
+	raw = strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(raw, operator)), "v")
+	v, err := version.NewVersion(raw)
+	if err != nil {
+		return nil, nil
+	}
+
+	switch operator {
+	case "", "=":
+		return &versionBound{version: v, inclusive: true}, &versionBound{version: v, inclusive: true}
+	case ">=":
+		return &versionBound{version: v, inclusive: true}, nil
+	case ">":
+		return &versionBound{version: v}, nil
+	case "<=":
+		return nil, &versionBound{version: v, inclusive: true}
+	case "<":
+		return nil, &versionBound{version: v}
+	case "~>":
+		// `~> 1.5` allows any 1.x from 1.5, `~> 1.5.0` any 1.5.x
+		segments := v.Segments()
+		written := len(strings.Split(strings.SplitN(raw, "-", 2)[0], "."))
+		bumped := 0
+		if written > 1 {
+			bumped = written - 2
+		}
+		next := make([]string, bumped+1)
+		for i := 0; i < bumped; i++ {
+			next[i] = fmt.Sprint(segments[i])
+		}
+		next[bumped] = fmt.Sprint(segments[bumped] + 1)
+		upper, err := version.NewVersion(strings.Join(next, "."))
+		if err != nil {
+			return &versionBound{version: v, inclusive: true}, nil
+		}
+		return &versionBound{version: v, inclusive: true}, &versionBound{version: upper}
+	}
+	return nil, nil
+}
+
+// Checks if `a` allows fewer versions than `b`, as lower bounds
+func isHigherLowerBound(a *versionBound, b *versionBound) bool {
+	comparison := a.version.Compare(b.version)
+	return comparison > 0 || (comparison == 0 && !a.inclusive && b.inclusive)
+}
+
+// Checks if `a` allows fewer versions than `b`, as upper bounds
+func isLowerUpperBound(a *versionBound, b *versionBound) bool {
+	comparison := a.version.Compare(b.version)
+	return comparison < 0 || (comparison == 0 && !a.inclusive && b.inclusive)
+}
+
+// Returns the version a constraint pins exactly, like `1.5.7` or `= 1.5.7`, or an empty string
+func exactVersion(constraint string) string {
+	constraints, err := version.NewConstraint(constraint)
+	if err != nil || len(constraints) != 1 {
+		return ""
+	}
+
+	raw := strings.TrimSpace(constraint)
+	raw = strings.TrimSpace(strings.TrimPrefix(raw, "="))
+	raw = strings.TrimPrefix(raw, "v")
+	v, err := version.NewVersion(raw)
+	if err != nil {
+		return ""
+	}
+
+	return v.String()
+}
+
+// Checks a version against a constraint, treating unparseable constraints as satisfied
+func versionSatisfies(v string, constraint string) bool {
+	parsedVersion, err := version.NewVersion(v)
+	if err != nil {
+		return true
+	}
+
+	constraints, err := version.NewConstraint(constraint)
+	if err != nil {
+		return true
+	}
+
+	return constraints.Check(parsedVersion)
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Returns the context generation runs in, which is cancelled on SIGINT or SIGTERM, and once `--timeout` has passed.
+// After the first signal, signals are no longer caught, so a second one stops the process right away.
+func generationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	cancel := stop
+	if generationTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, generationTimeout)
+		cancel = func() {
+			cancelTimeout()
+			stop()
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, cancel
+}
+
+// Tracks the configs being evaluated, so they can be named when generation is cut short
+type unitTracker struct {
+	mtx   sync.Mutex
+	units map[string]bool
+}
+
+func newUnitTracker() *unitTracker {
+	return &unitTracker{units: map[string]bool{}}
+}
+
+func (t *unitTracker) start(path string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.units[path] = true
+}
+
+func (t *unitTracker) finish(path string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	delete(t.units, path)
+}
+
+// Returns the configs being evaluated, sorted
+func (t *unitTracker) list() []string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return sortedKeys(t.units)
+}
+
+// Evaluates a single config with `create`, giving up on it after `--unit-timeout`, or once `ctx` is done.
+// Terragrunt functions like `run_cmd` don't stop when their context is cancelled, so a config that is given
+// up on keeps being evaluated in the background, but its result is thrown away, and it only uses the state
+// of the run in `ctx`, see `generationRun`. The returned channel is closed once the evaluation has returned.
+func runUnit(ctx context.Context, path string, create func(ctx context.Context) (*AtlantisProject, error)) (*AtlantisProject, <-chan struct{}, error) {
+	unitCtx, cancel := ctx, context.CancelFunc(func() {})
+	if unitTimeout > 0 {
+		unitCtx, cancel = context.WithTimeout(ctx, unitTimeout)
+	}
+	defer cancel()
+
+	type result struct {
+		project *AtlantisProject
+		err     error
+	}
+	done := make(chan result, 1)
+	finished := make(chan struct{})
+	run := runOf(ctx)
+	run.evaluations.Add(1)
+	go func() {
+		defer run.evaluations.Done()
+		defer close(finished)
+		// A panic fails only the config being evaluated, so `--keep-going` can still generate the others
+		defer func() {
+			if value := recover(); value != nil {
+				log.Debug("Panic while evaluating ", path, ": ", value, "\n", string(debug.Stack()))
+				done <- result{nil, &unitPanicError{path: path, value: value}}
+			}
+		}()
+
+		project, err := create(unitCtx)
+		done <- result{project, err}
+	}()
+
+	select {
+	case result := <-done:
+		// Errors of a unit that was cut short are likely caused by it, like a `run_cmd` that was interrupted
+		if result.err == nil || unitCtx.Err() == nil {
+			return result.project, finished, result.err
+		}
+	case <-unitCtx.Done():
+	}
+
+	if ctx.Err() != nil {
+		return nil, finished, ctx.Err()
+	}
+	return nil, finished, &unitTimeoutError{path: path, timeout: unitTimeout}
+}
+
+// Returned when evaluating a single config takes longer than `--unit-timeout`
+type unitTimeoutError struct {
+	path    string
+	timeout time.Duration
+}
+
+func (e *unitTimeoutError) Error() string {
+	return fmt.Sprintf("%s did not finish within the unit timeout of %s", e.path, e.timeout)
+}
+
+// Returned when evaluating a single config panics
+type unitPanicError struct {
+	path  string
+	value interface{}
+}
+
+func (e *unitPanicError) Error() string {
+	return fmt.Sprintf("%s: panicked while being evaluated: %v", e.path, e.value)
+}
+
+// Returned when generation times out or is interrupted by a signal before every config was evaluated
+type generationInterruptedError struct {
+	// Whether generation ran out of time, rather than being interrupted
+	timedOut bool
+
+	// The configs still being evaluated, relative to the root
+	units []string
+}
+
+func (e *generationInterruptedError) Error() string {
+	reason := "generation was interrupted"
+	if e.timedOut {
+		reason = fmt.Sprintf("generation timed out after %s", generationTimeout)
+	}
+	if len(e.units) == 0 {
+		return reason
+	}
+	return fmt.Sprintf("%s while still processing %s", reason, strings.Join(e.units, ", "))
+}
+
+// Reports the configs still being evaluated when the context of generation was done, returning the error
+// that ends the run
+func reportInterruptedUnits(ctx context.Context, units []string) error {
+	err := &generationInterruptedError{timedOut: goerrors.Is(ctx.Err(), context.DeadlineExceeded)}
+	for _, path := range units {
+		relativePath, relErr := filepath.Rel(gitRoot, path)
+		if relErr != nil {
+			relativePath = path
+		}
+		err.units = append(err.units, filepath.ToSlash(relativePath))
+		log.Error("Still processing ", path)
+		reportDiagnostic(ctx, ruleUnitInterrupted, path, nil, "%s was still being processed when generation stopped", relativePath)
+	}
+	return err
+}
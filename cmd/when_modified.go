@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Cleans, minimizes and sorts a `when_modified` list so the output is stable between runs:
+//   - paths are cleaned, so `../a/../b/*.tf*` becomes `../b/*.tf*`
+//   - patterns that are fully covered by a broader pattern, like `../modules/vpc/*.tf*` next to
+//     `../modules/**/*.tf*`, are dropped
+//   - patterns are sorted, with `!` exclusions kept at the end of the list so they still apply
+func normalizeWhenModified(patterns []string) []string {
+	includes := []string{}
+	excludes := []string{}
+	for _, pattern := range uniqueStrings(patterns) {
+		if strings.HasPrefix(pattern, "!") {
+			excludes = append(excludes, path.Clean(strings.TrimPrefix(pattern, "!")))
+		} else {
+			includes = append(includes, path.Clean(pattern))
+		}
+	}
+
+	normalized := dropSubsumedPatterns(includes)
+	for _, exclude := range dropSubsumedPatterns(excludes) {
+		normalized = append(normalized, "!"+exclude)
+	}
+	return normalized
+}
+
+// Removes duplicates and patterns covered by another pattern in the list, returning the rest sorted
+func dropSubsumedPatterns(patterns []string) []string {
+	patterns = uniqueStrings(patterns)
+	sort.Strings(patterns)
+
+	kept := []string{}
+	for i, pattern := range patterns {
+		subsumed := false
+		for j, other := range patterns {
+			if i != j && patternIncludes(other, pattern) {
+				subsumed = true
+				break
+			}
+		}
+		if !subsumed {
+			kept = append(kept, pattern)
+		}
+	}
+	return kept
+}
+
+// Checks if every path matched by the glob `narrow` is also matched by the glob `broad`.
+// Only `*`, `?` and `**` are understood, any pattern using other glob syntax only includes itself
+func patternIncludes(broad string, narrow string) bool {
+	if broad == narrow || strings.ContainsAny(broad+narrow, "[]{}\\") {
+		return false
+	}
+	return segmentsInclude(strings.Split(broad, "/"), strings.Split(narrow, "/"))
+}
+
+func segmentsInclude(broad []string, narrow []string) bool {
+	if len(broad) == 0 {
+		return len(narrow) == 0
+	}
+
+	if broad[0] == "**" {
+		// `**` matches zero or more directories, but never climbs out of the project with `..`
+		if segmentsInclude(broad[1:], narrow) {
+			return true
+		}
+		return len(narrow) > 0 && narrow[0] != ".." && segmentsInclude(broad, narrow[1:])
+	}
+
+	if len(narrow) == 0 || narrow[0] == "**" {
+		return false
+	}
+	// Like `**`, wildcards never match `..`, so only `..` itself includes it
+	if narrow[0] == ".." {
+		return broad[0] == ".." && segmentsInclude(broad[1:], narrow[1:])
+	}
+
+	return segmentIncludes(broad[0], narrow[0]) && segmentsInclude(broad[1:], narrow[1:])
+}
+
+// Checks if every name matched by the single path segment glob `narrow` is matched by `broad`
+func segmentIncludes(broad string, narrow string) bool {
+	if broad == "" {
+		return narrow == ""
+	}
+
+	switch broad[0] {
+	case '*':
+		if segmentIncludes(broad[1:], narrow) {
+			return true
+		}
+		return narrow != "" && segmentIncludes(broad, narrow[1:])
+	case '?':
+		return narrow != "" && narrow[0] != '*' && segmentIncludes(broad[1:], narrow[1:])
+	}
+
+	return narrow != "" && narrow[0] == broad[0] && segmentIncludes(broad[1:], narrow[1:])
+}
@@ -0,0 +1,11 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "left" {
+  config_path = "../left"
+}
+
+dependency "right" {
+  config_path = "../right"
+}
@@ -0,0 +1,11 @@
+terraform {
+  source = "../modules/app"
+}
+
+dependency "db" {
+  config_path = "../db"
+}
+
+inputs = {
+  database_url = dependency.db.outputs.url
+}
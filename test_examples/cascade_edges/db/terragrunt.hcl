@@ -0,0 +1,11 @@
+terraform {
+  source = "../modules/db"
+}
+
+dependency "network" {
+  config_path = "../network"
+}
+
+inputs = {
+  subnet = dependency.network.outputs.subnet
+}
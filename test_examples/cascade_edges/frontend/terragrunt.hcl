@@ -0,0 +1,11 @@
+terraform {
+  source = "../modules/app"
+}
+
+dependency "app" {
+  config_path = "../app"
+}
+
+inputs = {
+  database_url = dependency.app.outputs.url
+}
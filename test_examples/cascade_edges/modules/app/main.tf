@@ -0,0 +1 @@
+variable "database_url" {}
@@ -0,0 +1,18 @@
+locals {
+  atlantis_cascade = {
+    edges = ["dependency", "source"]
+    depth = 1
+  }
+}
+
+terraform {
+  source = "../modules/app"
+}
+
+dependency "app" {
+  config_path = "../app"
+}
+
+inputs = {
+  database_url = dependency.app.outputs.url
+}
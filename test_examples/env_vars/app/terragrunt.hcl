@@ -0,0 +1,9 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  stage = get_env("ATLANTIS_CONFIG_STAGE", "dev")
+
+  atlantis_workflow = local.stage
+}
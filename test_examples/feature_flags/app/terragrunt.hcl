@@ -0,0 +1,11 @@
+feature "use_legacy_vpc" {
+  default = false
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "vpc" {
+  config_path = feature.use_legacy_vpc.value ? "../legacy_vpc" : "../vpc"
+}
@@ -0,0 +1,3 @@
+terraform {
+  source = "git::git@github.com:terraform-aws-modules/terraform-aws-vpc.git?ref=v5.0.0"
+}
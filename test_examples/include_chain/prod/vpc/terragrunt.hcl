@@ -0,0 +1,3 @@
+include "envcommon" {
+  path = find_in_parent_folders("_envcommon/vpc.hcl")
+}
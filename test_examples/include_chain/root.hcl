@@ -0,0 +1,4 @@
+locals {
+  atlantis_workflow           = "root"
+  atlantis_apply_requirements = ["approved"]
+}
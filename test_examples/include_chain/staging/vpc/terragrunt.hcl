@@ -0,0 +1,7 @@
+include "envcommon" {
+  path = find_in_parent_folders("_envcommon/vpc.hcl")
+}
+
+locals {
+  atlantis_workflow = "staging"
+}
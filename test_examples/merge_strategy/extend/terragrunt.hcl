@@ -0,0 +1,12 @@
+include "root" {
+  path = find_in_parent_folders("root.hcl")
+}
+
+locals {
+  atlantis_apply_requirements = ["approved", "mergeable"]
+  extra_atlantis_dependencies = ["${get_parent_terragrunt_dir()}/shared.json"]
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
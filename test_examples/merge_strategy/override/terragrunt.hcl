@@ -0,0 +1,17 @@
+include "root" {
+  path = find_in_parent_folders("root.hcl")
+}
+
+locals {
+  atlantis_apply_requirements = ["mergeable"]
+  extra_atlantis_dependencies = ["own.json"]
+
+  atlantis_merge_strategy = {
+    atlantis_apply_requirements = "replace"
+    extra_atlantis_dependencies = "replace"
+  }
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,8 @@
+locals {
+  atlantis_apply_requirements = ["approved"]
+  extra_atlantis_dependencies = ["${get_parent_terragrunt_dir()}/shared.json"]
+
+  atlantis_merge_strategy = {
+    atlantis_apply_requirements = "deep"
+  }
+}
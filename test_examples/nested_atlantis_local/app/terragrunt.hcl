@@ -0,0 +1,18 @@
+include "root" {
+  path = find_in_parent_folders("root.hcl")
+}
+
+locals {
+  atlantis = {
+    workflow           = "nested"
+    autoplan           = true
+    extra_dependencies = ["config.json"]
+  }
+
+  # Takes precedence over atlantis.workflow
+  atlantis_workflow = "prefixed"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,6 @@
+locals {
+  atlantis = {
+    workflow           = "parent"
+    apply_requirements = ["approved"]
+  }
+}
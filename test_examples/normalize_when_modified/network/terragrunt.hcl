@@ -0,0 +1,16 @@
+terraform {
+  source = "../modules/vpc"
+}
+
+locals {
+  extra_atlantis_dependencies = [
+    "../modules/**/*.tf*",
+    "../a/../b/config.json",
+    "./versions.json",
+  ]
+  atlantis_when_modified_exclude = ["docs/**", "docs/*.md", "./README.md"]
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,7 @@
+terraform {
+  source = "../modules/tofu"
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,9 @@
+locals {
+  atlantis = {
+    is_parent = true
+  }
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,3 @@
+locals {
+  atlantis_is_parent = false
+}
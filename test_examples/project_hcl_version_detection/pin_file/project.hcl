@@ -0,0 +1,3 @@
+locals {
+  atlantis_project = true
+}
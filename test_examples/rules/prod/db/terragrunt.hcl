@@ -0,0 +1,8 @@
+locals {
+  # Takes precedence over the rules
+  atlantis_workflow = "db"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,2 @@
+workflow           = "sidecar"
+apply_requirements = ["approved"]
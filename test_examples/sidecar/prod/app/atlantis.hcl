@@ -0,0 +1,2 @@
+workflow            = "app"
+when_modified_extra = ["config.json"]
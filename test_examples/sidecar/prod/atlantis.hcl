@@ -0,0 +1,2 @@
+autoplan           = false
+apply_requirements = ["approved", "mergeable"]
@@ -0,0 +1,7 @@
+terraform {
+  required_version = "1.4.6"
+}
+
+module "nested" {
+  source = "./nested"
+}
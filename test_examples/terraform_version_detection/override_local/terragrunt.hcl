@@ -0,0 +1,11 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  atlantis_terraform_version = "0.13.9001"
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,7 @@
+terraform {
+  source = "../modules/pinned"
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,16 @@
+include {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  atlantis_when_modified_extra   = ["*.json"]
+  atlantis_when_modified_exclude = ["README.md"]
+}
+
+inputs = {
+  foo = "bar"
+}
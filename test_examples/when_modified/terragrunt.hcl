@@ -0,0 +1,3 @@
+locals {
+  atlantis_when_modified_exclude = ["docs/**"]
+}
@@ -0,0 +1,7 @@
+locals {
+  extra_atlantis_dependencies = ["../shared/config.hcl"]
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
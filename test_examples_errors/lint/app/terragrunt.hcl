@@ -0,0 +1,15 @@
+include "root" {
+  path = find_in_parent_folders("root.hcl")
+}
+
+locals {
+  atlantis_autoplan           = "false"
+  atlantis_workflow           = 3
+  atlantis_apply_requirements = ["approved", true]
+  atlantis_skip               = false
+  atlantis_custom_setting     = "not close to any known local"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
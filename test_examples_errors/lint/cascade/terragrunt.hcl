@@ -0,0 +1,8 @@
+locals {
+  atlantis_cascade        = { enabled = "yes" }
+  atlantis_merge_strategy = { when_modified = 3 }
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,2 @@
+workflow = ["sidecar"]
+skip     = false
@@ -0,0 +1,12 @@
+locals {
+  atlantis = {
+    workflow               = "nested"
+    autoplan               = "yes"
+    terraform_distribution = "unknown"
+    aply_requirements      = ["approved"]
+  }
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,8 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  working_dir = get_working_dir()
+  marker      = run_cmd("--terragrunt-quiet", "touch", get_env("SANDBOX_MARKER"))
+}
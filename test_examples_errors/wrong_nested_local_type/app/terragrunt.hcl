@@ -0,0 +1,9 @@
+locals {
+  atlantis = {
+    autoplan = "false"
+  }
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
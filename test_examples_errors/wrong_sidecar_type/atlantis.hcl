@@ -0,0 +1 @@
+workflow = 3